@@ -0,0 +1,292 @@
+// Package backlight provides a screen brightness indicator that scrolls
+// through perceptually even steps using systemd-logind, so no root or udev
+// rules are needed.
+package backlight
+
+import (
+	"log"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/base/value"
+	"barista.run/outputs"
+	"barista.run/timing"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/godbus/dbus/v5"
+)
+
+// Info is the brightness of a backlight.
+type Info struct {
+	// Name is the backlight device, e.g. "intel_backlight".
+	Name string
+	// Brightness and Max are the raw current and maximum brightness.
+	Brightness, Max int
+	// Toggled reports whether the saved level is active (see Toggle).
+	Toggled bool
+
+	m *Module
+}
+
+// Level returns the perceived brightness between 0 and 1.
+func (i Info) Level() float64 {
+	return perceived(i.Brightness, i.Max)
+}
+
+// Pct returns the perceived brightness as a percentage.
+func (i Info) Pct() int {
+	return int(i.Level()*100 + 0.5)
+}
+
+// Up increases the brightness by one perceived step.
+func (i Info) Up() {
+	i.m.set(i.Name, step(i.Brightness, i.Max, i.m.step))
+}
+
+// Down decreases the brightness by one perceived step.
+func (i Info) Down() {
+	i.m.set(i.Name, step(i.Brightness, i.Max, -i.m.step))
+}
+
+// Toggle switches to the saved level, or back to the brightness from before
+// the previous toggle.
+func (i Info) Toggle() {
+	i.m.mu.Lock()
+	defer i.m.mu.Unlock()
+	if i.m.restore > 0 {
+		i.m.set(i.Name, i.m.restore)
+		i.m.restore = 0
+	} else {
+		i.m.restore = i.Brightness
+		i.m.set(i.Name, raw(i.m.saved, i.Max))
+	}
+	i.m.refresh.Set(struct{}{})
+}
+
+// Module represents a backlight barista module.
+type Module struct {
+	sysfsRoot  string
+	name       string
+	step       float64
+	saved      float64
+	scheduler  *timing.Scheduler
+	outputFunc value.Value // of func(Info) bar.Output
+	refresh    value.Value // of struct{}
+
+	mu      sync.Mutex // guards restore
+	restore int
+	connMu  sync.Mutex // guards conn
+	conn    *dbus.Conn
+}
+
+// New constructs a backlight module for the preferred backlight device.
+func New() *Module {
+	return Named("")
+}
+
+// Named constructs a backlight module for the given device in
+// /sys/class/backlight.
+func Named(name string) *Module {
+	m := &Module{
+		sysfsRoot: "/sys",
+		name:      name,
+		step:      0.05,
+		saved:     0.3,
+		scheduler: timing.NewScheduler().Every(time.Minute),
+	}
+	m.Output(func(i Info) bar.Output {
+		return outputs.Textf("%d%%", i.Pct()).OnClick(func(e bar.Event) {
+			switch e.Button {
+			case bar.ScrollUp:
+				i.Up()
+			case bar.ScrollDown:
+				i.Down()
+			case bar.ButtonLeft:
+				i.Toggle()
+			}
+		})
+	})
+	return m
+}
+
+// Step sets the perceived brightness change per scroll step, 0.05 by
+// default.
+func (m *Module) Step(step float64) *Module {
+	m.step = step
+	return m
+}
+
+// SavedLevel sets the perceived brightness Toggle switches to, 0.3 by
+// default.
+func (m *Module) SavedLevel(level float64) *Module {
+	m.saved = level
+	return m
+}
+
+// SysfsRoot sets the directory backlights are read from, "/sys" by default.
+func (m *Module) SysfsRoot(root string) *Module {
+	m.sysfsRoot = root
+	return m
+}
+
+// RefreshInterval configures how often the brightness is read in addition
+// to watching it for changes.
+func (m *Module) RefreshInterval(interval time.Duration) *Module {
+	m.scheduler.Every(interval)
+	return m
+}
+
+// Output sets the output format for the module.
+func (m *Module) Output(outputFunc func(Info) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+// Stream starts the module.
+func (m *Module) Stream(sink bar.Sink) {
+	dev, err := findDevice(m.sysfsRoot, m.name)
+	if sink.Error(err) {
+		return
+	}
+	// The kernel notifies watchers of actual_brightness on every change,
+	// including hotkeys handled by the firmware. If watching fails the
+	// periodic refresh takes over.
+	var events <-chan fsnotify.Event
+	if watcher, err := fsnotify.NewWatcher(); err != nil {
+		log.Printf("failed to create backlight watcher: %v", err)
+	} else {
+		defer watcher.Close()
+		if err := watcher.Add(dev.path); err != nil {
+			log.Printf("failed to watch %s: %v", dev.path, err)
+		}
+		events = watcher.Events
+	}
+	defer m.close()
+
+	info, err := m.read(dev)
+	outputFunc := m.outputFunc.Get().(func(Info) bar.Output)
+	nextOutputFunc, done := m.outputFunc.Subscribe()
+	defer done()
+	nextRefresh, done := m.refresh.Subscribe()
+	defer done()
+	for {
+		if sink.Error(err) {
+			return
+		}
+		sink.Output(outputFunc(info))
+		select {
+		case event, ok := <-events:
+			if !ok {
+				events = nil
+				continue
+			}
+			switch filepath.Base(event.Name) {
+			case "brightness", "actual_brightness":
+				info, err = m.read(dev)
+			default:
+				continue
+			}
+		case <-nextRefresh:
+			info, err = m.read(dev)
+		case <-m.scheduler.C:
+			info, err = m.read(dev)
+		case <-nextOutputFunc:
+			outputFunc = m.outputFunc.Get().(func(Info) bar.Output)
+		}
+	}
+}
+
+func (m *Module) read(dev device) (Info, error) {
+	brightness, max, err := dev.read()
+	if err != nil {
+		return Info{}, err
+	}
+	m.mu.Lock()
+	toggled := m.restore > 0
+	m.mu.Unlock()
+	return Info{Name: dev.name, Brightness: brightness, Max: max, Toggled: toggled, m: m}, nil
+}
+
+// set sets the brightness of the named backlight, logging failures.
+func (m *Module) set(name string, brightness int) {
+	conn, err := m.systemBus()
+	if err != nil {
+		log.Printf("failed to connect to system bus: %v", err)
+		return
+	}
+	if err := setBrightness(conn, name, brightness); err != nil {
+		log.Printf("failed to set brightness of %s: %v", name, err)
+	}
+}
+
+// Dim lowers the named backlight below sysfsRoot, or the preferred one if
+// name is empty, to the given perceived level unless it is already darker.
+// The returned function restores the previous brightness.
+func Dim(sysfsRoot, name string, level float64) (restore func() error, err error) {
+	dev, err := findDevice(sysfsRoot, name)
+	if err != nil {
+		return nil, err
+	}
+	brightness, max, err := dev.read()
+	if err != nil {
+		return nil, err
+	}
+	if perceived(brightness, max) <= level {
+		return func() error { return nil }, nil
+	}
+	if err := withSystemBus(func(conn *dbus.Conn) error {
+		return setBrightness(conn, dev.name, raw(level, max))
+	}); err != nil {
+		return nil, err
+	}
+	return func() error {
+		return withSystemBus(func(conn *dbus.Conn) error {
+			return setBrightness(conn, dev.name, brightness)
+		})
+	}, nil
+}
+
+// setBrightness sets the brightness through the SetBrightness method of the
+// logind session, which is allowed for the user of the active session.
+func setBrightness(conn *dbus.Conn, name string, brightness int) error {
+	session := conn.Object("org.freedesktop.login1", "/org/freedesktop/login1/session/auto")
+	return session.Call("org.freedesktop.login1.Session.SetBrightness", 0, "backlight", name, uint32(brightness)).Err
+}
+
+// withSystemBus calls f with a private system bus connection that is closed
+// afterwards.
+func withSystemBus(f func(conn *dbus.Conn) error) error {
+	conn, err := dbus.ConnectSystemBus()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	return f(conn)
+}
+
+// systemBus returns a private system bus connection, connecting on first
+// use.
+func (m *Module) systemBus() (*dbus.Conn, error) {
+	m.connMu.Lock()
+	defer m.connMu.Unlock()
+	if m.conn != nil && m.conn.Connected() {
+		return m.conn, nil
+	}
+	conn, err := dbus.ConnectSystemBus()
+	if err != nil {
+		return nil, err
+	}
+	m.conn = conn
+	return conn, nil
+}
+
+func (m *Module) close() {
+	m.connMu.Lock()
+	defer m.connMu.Unlock()
+	if m.conn != nil {
+		_ = m.conn.Close()
+		m.conn = nil
+	}
+}
@@ -0,0 +1,115 @@
+package backlight
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// device is a backlight in /sys/class/backlight.
+type device struct {
+	name string
+	path string
+}
+
+// typePriority prefers firmware interfaces over platform specific ones over
+// raw hardware registers, as recommended by the kernel documentation.
+var typePriority = map[string]int{
+	"firmware": 0,
+	"platform": 1,
+	"raw":      2,
+}
+
+// findDevice returns the named backlight, or the preferred one if name is
+// empty.
+func findDevice(sysfsRoot, name string) (device, error) {
+	dir := filepath.Join(sysfsRoot, "class", "backlight")
+	if name != "" {
+		return device{name: name, path: filepath.Join(dir, name)}, nil
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return device{}, err
+	}
+	var devices []device
+	for _, entry := range entries {
+		devices = append(devices, device{name: entry.Name(), path: filepath.Join(dir, entry.Name())})
+	}
+	if len(devices) == 0 {
+		return device{}, fmt.Errorf("no backlight found in %s", dir)
+	}
+	priority := func(d device) int {
+		data, _ := os.ReadFile(filepath.Join(d.path, "type"))
+		if p, ok := typePriority[strings.TrimSpace(string(data))]; ok {
+			return p
+		}
+		return len(typePriority)
+	}
+	sort.SliceStable(devices, func(i, j int) bool {
+		return priority(devices[i]) < priority(devices[j])
+	})
+	return devices[0], nil
+}
+
+// read returns the current and the maximum brightness.
+func (d device) read() (brightness, max int, err error) {
+	brightness, err = readInt(filepath.Join(d.path, "brightness"))
+	if err != nil {
+		return 0, 0, err
+	}
+	max, err = readInt(filepath.Join(d.path, "max_brightness"))
+	return brightness, max, err
+}
+
+func readInt(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(string(data)))
+}
+
+// decades is the dynamic range of the perceived level: level 0 is 1% of
+// the maximum brightness.
+const decades = 2
+
+// perceived converts a raw brightness to the perceived level between 0 and 1.
+// Perceived brightness is roughly logarithmic, so equal steps of the level
+// look like equal changes while the raw steps grow with the brightness.
+func perceived(brightness, max int) float64 {
+	if brightness <= 0 || max <= 0 {
+		return 0
+	}
+	level := 1 + math.Log10(float64(brightness)/float64(max))/decades
+	return math.Max(0, math.Min(1, level))
+}
+
+// raw converts a perceived level between 0 and 1 to a raw brightness.
+func raw(level float64, max int) int {
+	level = math.Max(0, math.Min(1, level))
+	return int(math.Round(float64(max) * math.Pow(10, (level-1)*decades)))
+}
+
+// step returns the raw brightness one perceived step away from brightness.
+// The result always differs by at least one raw unit and never turns the
+// backlight off.
+func step(brightness, max int, delta float64) int {
+	next := raw(perceived(brightness, max)+delta, max)
+	switch {
+	case delta > 0 && next <= brightness:
+		next = brightness + 1
+	case delta < 0 && next >= brightness:
+		next = brightness - 1
+	}
+	if next < 1 {
+		next = 1
+	}
+	if next > max {
+		next = max
+	}
+	return next
+}
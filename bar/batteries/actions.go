@@ -0,0 +1,118 @@
+package batteries
+
+import (
+	"fmt"
+	"log"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/tionis/i3-tools/bar/backlight"
+)
+
+// Action is what to do when the battery runs low: one of the predefined
+// actions below, or any other shell command.
+type Action string
+
+const (
+	// NotifyAction sends a critical desktop notification.
+	NotifyAction Action = "notify"
+	// DimAction dims the screen through logind, restoring the previous
+	// brightness once the battery is charging again.
+	DimAction Action = "dim"
+	// SuspendAction suspends the system through systemd.
+	SuspendAction Action = "suspend"
+	// HibernateAction hibernates the system through systemd.
+	HibernateAction Action = "hibernate"
+)
+
+// dimLevel is the perceived backlight level DimAction dims to, which is 10%
+// of the maximum brightness.
+const dimLevel = 0.5
+
+// Escalation runs an action once the combined charge is below a level
+// while discharging. Each action runs only once until the battery is
+// charging again.
+type Escalation struct {
+	Level  int
+	Action Action
+}
+
+// ParseEscalation converts "level:action" pairs, e.g. "3:hibernate", to
+// escalations sorted from the highest to the lowest level.
+func ParseEscalation(specs []string) ([]Escalation, error) {
+	var steps []Escalation
+	for _, spec := range specs {
+		level, action, ok := strings.Cut(spec, ":")
+		if !ok {
+			return nil, fmt.Errorf("battery action %q is not level:action", spec)
+		}
+		pct, err := strconv.Atoi(strings.TrimSpace(level))
+		if err != nil {
+			return nil, fmt.Errorf("battery action %q: %w", spec, err)
+		}
+		steps = append(steps, Escalation{Level: pct, Action: Action(strings.TrimSpace(action))})
+	}
+	sort.SliceStable(steps, func(i, j int) bool {
+		return steps[i].Level > steps[j].Level
+	})
+	return steps, nil
+}
+
+// escalate runs the actions for all levels the battery is below while
+// discharging, unless snoozed. This includes levels it was already below
+// when starting or that it dropped below while snoozed. Going back on mains
+// power resets them and restores the brightness.
+func (m *Module) escalate(cur Info) {
+	if cur.AC || !cur.Discharging() {
+		if m.restoreBrightness != nil {
+			if err := m.restoreBrightness(); err != nil {
+				log.Printf("failed to restore brightness: %v", err)
+			}
+			m.restoreBrightness = nil
+		}
+		m.fired = nil
+		return
+	}
+	if cur.Snoozed() {
+		return
+	}
+	for _, step := range m.escalation {
+		if cur.RemainingPct() >= step.Level || m.fired[step] {
+			continue
+		}
+		if m.fired == nil {
+			m.fired = map[Escalation]bool{}
+		}
+		m.fired[step] = true
+		m.runAction(step, cur)
+	}
+}
+
+func (m *Module) runAction(step Escalation, info Info) {
+	switch step.Action {
+	case NotifyAction:
+		sendNotification("critical", fmt.Sprintf("Battery below %d%%", step.Level),
+			fmt.Sprintf("%d%% remaining, %s left", info.RemainingPct(), info.RemainingTime()))
+	case DimAction:
+		if m.restoreBrightness == nil {
+			restore, err := backlight.Dim(m.sysfsRoot, m.backlight, dimLevel)
+			if err != nil {
+				log.Printf("failed to dim the screen: %v", err)
+				return
+			}
+			m.restoreBrightness = restore
+		}
+	case SuspendAction, HibernateAction:
+		run(exec.Command("systemctl", string(step.Action)))
+	default:
+		run(exec.Command("sh", "-c", string(step.Action)))
+	}
+}
+
+func run(cmd *exec.Cmd) {
+	if err := cmd.Run(); err != nil {
+		log.Printf("failed to run low battery action %q: %v", cmd.String(), err)
+	}
+}
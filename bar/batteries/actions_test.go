@@ -0,0 +1,71 @@
+package batteries
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"barista.run/modules/battery"
+)
+
+func TestParseEscalation(t *testing.T) {
+	steps, err := ParseEscalation([]string{"3:suspend", "15:notify", " 8 : dim "})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Escalation{{15, NotifyAction}, {8, DimAction}, {3, SuspendAction}}
+	if !reflect.DeepEqual(steps, want) {
+		t.Errorf("got %v, want %v", steps, want)
+	}
+	for _, spec := range []string{"suspend", "low:suspend"} {
+		if _, err := ParseEscalation([]string{spec}); err == nil {
+			t.Errorf("no error for %q", spec)
+		}
+	}
+}
+
+func TestEscalate(t *testing.T) {
+	log := filepath.Join(t.TempDir(), "actions")
+	m := New().Escalate(
+		Escalation{Level: 15, Action: Action("echo 15 >>" + log)},
+		Escalation{Level: 8, Action: Action("echo 8 >>" + log)},
+	)
+	at := func(pct int, status battery.Status) Info {
+		return Info{Info: battery.Info{EnergyNow: float64(pct), EnergyFull: 100, Status: status}}
+	}
+	snoozed := func(i Info) Info {
+		i.SnoozedUntil = time.Now().Add(time.Hour)
+		return i
+	}
+	for _, tc := range []struct {
+		name string
+		info Info
+		want []string
+	}{
+		{"already low on start", at(10, battery.Discharging), []string{"15"}},
+		{"still low", at(9, battery.Discharging), nil},
+		{"dropped below", at(7, battery.Discharging), []string{"8"}},
+		{"fluctuating", at(9, battery.Discharging), nil},
+		{"below again", at(7, battery.Discharging), nil},
+		{"charging", at(20, battery.Charging), nil},
+		{"above all levels", at(16, battery.Discharging), nil},
+		{"dropped below both", at(5, battery.Discharging), []string{"15", "8"}},
+		{"charging again", at(20, battery.Charging), nil},
+		{"snoozed", snoozed(at(12, battery.Discharging)), nil},
+		{"drained while snoozed", snoozed(at(4, battery.Discharging)), nil},
+		{"snooze ended", at(4, battery.Discharging), []string{"15", "8"}},
+	} {
+		m.escalate(tc.info)
+		data, err := os.ReadFile(log)
+		if err != nil && !os.IsNotExist(err) {
+			t.Fatal(err)
+		}
+		if got := strings.Fields(string(data)); strings.Join(got, " ") != strings.Join(tc.want, " ") {
+			t.Errorf("%s: ran actions %v, want %v", tc.name, got, tc.want)
+		}
+		os.Remove(log)
+	}
+}
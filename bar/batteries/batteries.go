@@ -0,0 +1,180 @@
+// Package batteries provides a battery indicator with a per-pack breakdown,
+// health, cycle count and charge thresholds, and notifications when the
+// charge crosses configured levels.
+package batteries
+
+import (
+	"fmt"
+	"log"
+	"os/exec"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/base/value"
+	"barista.run/modules/battery"
+	"barista.run/outputs"
+	"barista.run/timing"
+)
+
+// Info holds the combined state of all batteries.
+type Info struct {
+	// Info combines all packs like barista's battery.All().
+	battery.Info
+	// Packs holds each battery sorted by name.
+	Packs []Pack
+	// AC reports whether mains power is connected.
+	AC bool
+	// SnoozedUntil is the time low battery actions are suspended until.
+	SnoozedUntil time.Time
+
+	snooze func(time.Duration)
+}
+
+// Snoozed reports whether low battery actions are currently suspended.
+func (i Info) Snoozed() bool {
+	return time.Now().Before(i.SnoozedUntil)
+}
+
+// Snooze suspends low battery actions for the given duration, or resumes
+// them if it is not positive.
+func (i Info) Snooze(d time.Duration) {
+	if i.snooze != nil {
+		i.snooze(d)
+	}
+}
+
+// Health returns the remaining share of the combined design capacity, or 0
+// if the design capacity is unknown.
+func (i Info) Health() float64 {
+	return health(i.Info)
+}
+
+// Module represents a batteries barista module.
+type Module struct {
+	sysfsRoot         string
+	backlight         string
+	levels            []int
+	escalation        []Escalation
+	fired             map[Escalation]bool
+	restoreBrightness func() error // set while dimmed
+	snoozedUntil      value.Value  // of time.Time
+	scheduler         *timing.Scheduler
+	outputFunc        value.Value // of func(Info) bar.Output
+}
+
+// New constructs a batteries module.
+func New() *Module {
+	m := &Module{
+		sysfsRoot: "/sys",
+		scheduler: timing.NewScheduler().Every(3 * time.Second),
+	}
+	m.snoozedUntil.Set(time.Time{})
+	m.Output(func(i Info) bar.Output {
+		return outputs.Textf("BATT %d%%", i.RemainingPct())
+	})
+	return m
+}
+
+// NotifyAt sends a desktop notification whenever the combined charge drops
+// below one of the levels while discharging, or reaches one while charging.
+func (m *Module) NotifyAt(levels ...int) *Module {
+	m.levels = levels
+	return m
+}
+
+// Escalate runs the given actions when the battery is below their levels
+// while discharging. Snoozing (see Info.Snooze) postpones them until the
+// snooze ends.
+func (m *Module) Escalate(steps ...Escalation) *Module {
+	m.escalation = steps
+	return m
+}
+
+// Backlight sets the backlight in /sys/class/backlight DimAction dims, the
+// preferred one by default.
+func (m *Module) Backlight(name string) *Module {
+	m.backlight = name
+	return m
+}
+
+// SysfsRoot sets the directory power supplies and backlights are read from,
+// "/sys" by default.
+func (m *Module) SysfsRoot(root string) *Module {
+	m.sysfsRoot = root
+	return m
+}
+
+// RefreshInterval configures the polling frequency.
+func (m *Module) RefreshInterval(interval time.Duration) *Module {
+	m.scheduler.Every(interval)
+	return m
+}
+
+// Output sets the output format for the module.
+func (m *Module) Output(outputFunc func(Info) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+// Stream starts the module.
+func (m *Module) Stream(sink bar.Sink) {
+	info, err := m.read()
+	if err == nil {
+		m.escalate(info)
+	}
+	outputFunc := m.outputFunc.Get().(func(Info) bar.Output)
+	nextOutputFunc, done := m.outputFunc.Subscribe()
+	defer done()
+	nextSnooze, done := m.snoozedUntil.Subscribe()
+	defer done()
+	for {
+		if sink.Error(err) {
+			return
+		}
+		sink.Output(outputFunc(info))
+		select {
+		case <-m.scheduler.C:
+			prev := info
+			info, err = m.read()
+			if err == nil {
+				m.notify(prev, info)
+				m.escalate(info)
+			}
+		case <-nextSnooze:
+			info.SnoozedUntil = m.snoozedUntil.Get().(time.Time)
+		case <-nextOutputFunc:
+			outputFunc = m.outputFunc.Get().(func(Info) bar.Output)
+		}
+	}
+}
+
+func (m *Module) read() (Info, error) {
+	info, err := Read(m.sysfsRoot)
+	info.SnoozedUntil = m.snoozedUntil.Get().(time.Time)
+	info.snooze = func(d time.Duration) {
+		m.snoozedUntil.Set(time.Now().Add(d))
+	}
+	return info, err
+}
+
+// notify sends a notification for each level crossed between two readings.
+func (m *Module) notify(prev, cur Info) {
+	before, after := prev.RemainingPct(), cur.RemainingPct()
+	for _, level := range m.levels {
+		switch {
+		case cur.Discharging() && before >= level && after < level:
+			sendNotification("critical", fmt.Sprintf("Battery below %d%%", level),
+				fmt.Sprintf("%d%% remaining, %s left", after, cur.RemainingTime()))
+		case cur.Status == battery.Charging && before < level && after >= level:
+			sendNotification("normal", fmt.Sprintf("Battery charged to %d%%", level),
+				fmt.Sprintf("%d%%, full in %s", after, cur.RemainingTime()))
+		}
+	}
+}
+
+func sendNotification(urgency, summary, body string) {
+	err := exec.Command("notify-send", "--app-name=i3-tools", "--urgency="+urgency, "--icon=battery", summary, body).Run()
+	if err != nil {
+		log.Printf("failed to send battery notification: %v", err)
+	}
+}
@@ -0,0 +1,217 @@
+package batteries
+
+import (
+	"bufio"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"barista.run/modules/battery"
+)
+
+// Pack is a single battery.
+type Pack struct {
+	// Name is the power supply name, e.g. "BAT0".
+	Name string
+	battery.Info
+	// CycleCount is the number of charge cycles, or 0 if unknown.
+	CycleCount int
+	// StartThreshold and EndThreshold are the percentages charging starts
+	// and stops at, or 0 if the driver does not support thresholds.
+	StartThreshold, EndThreshold int
+}
+
+// Health returns the remaining share of the design capacity, or 0 if the
+// design capacity is unknown.
+func (p Pack) Health() float64 {
+	return health(p.Info)
+}
+
+func health(i battery.Info) float64 {
+	if i.EnergyMax == 0 {
+		return 0
+	}
+	return i.EnergyFull / i.EnergyMax
+}
+
+// Read reads all batteries and whether the system runs on mains power from
+// the power_supply class below the given sysfs root, usually "/sys".
+func Read(sysfsRoot string) (Info, error) {
+	dir := filepath.Join(sysfsRoot, "class", "power_supply")
+	supplies, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return Info{Info: battery.Info{Status: battery.Disconnected}}, nil
+		}
+		return Info{}, err
+	}
+	var info Info
+	for _, supply := range supplies {
+		path := filepath.Join(dir, supply.Name())
+		switch readString(filepath.Join(path, "type")) {
+		case "Battery":
+			// Peripheral batteries (mice, headsets) have a scope of Device.
+			if readString(filepath.Join(path, "scope")) == "Device" {
+				continue
+			}
+			pack, err := readPack(path)
+			if err != nil {
+				return Info{}, err
+			}
+			info.Packs = append(info.Packs, pack)
+		case "Mains", "USB":
+			if readString(filepath.Join(path, "online")) == "1" {
+				info.AC = true
+			}
+		}
+	}
+	sort.Slice(info.Packs, func(i, j int) bool {
+		return info.Packs[i].Name < info.Packs[j].Name
+	})
+	info.Info = combine(info.Packs)
+	return info, nil
+}
+
+// readPack parses the uevent of a battery the same way as barista's battery
+// module, and additionally reads the cycle count and charge thresholds.
+func readPack(path string) (Pack, error) {
+	f, err := os.Open(filepath.Join(path, "uevent"))
+	if err != nil {
+		return Pack{}, err
+	}
+	defer f.Close()
+
+	pack := Pack{Name: filepath.Base(path)}
+	// Some drivers report charge in µAh and current in µA instead of
+	// energy in µWh and power in µW, which is converted using the voltage.
+	var energyNow, energyFull, energyMax, power float64
+	var inAmps, energyNowProvided bool
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		key, value, ok := strings.Cut(strings.TrimSpace(s.Text()), "=")
+		if !ok {
+			continue
+		}
+		switch strings.TrimPrefix(key, "POWER_SUPPLY_") {
+		case "CHARGE_NOW":
+			inAmps = true
+			energyNow, energyNowProvided = fromMicro(value), true
+		case "ENERGY_NOW":
+			energyNow, energyNowProvided = fromMicro(value), true
+		case "CHARGE_FULL":
+			inAmps = true
+			energyFull = fromMicro(value)
+		case "ENERGY_FULL":
+			energyFull = fromMicro(value)
+		case "CHARGE_FULL_DESIGN":
+			energyMax = fromMicro(value)
+		case "ENERGY_FULL_DESIGN":
+			energyMax = fromMicro(value)
+		case "CURRENT_NOW":
+			power = fromMicro(value)
+		case "POWER_NOW":
+			power = fromMicro(value)
+		case "VOLTAGE_NOW":
+			pack.Voltage = fromMicro(value)
+		case "STATUS":
+			pack.Status = status(value)
+		case "TECHNOLOGY":
+			pack.Technology = value
+		case "CAPACITY":
+			pack.Capacity, _ = strconv.Atoi(value)
+		case "CYCLE_COUNT":
+			pack.CycleCount, _ = strconv.Atoi(value)
+		}
+	}
+	if err := s.Err(); err != nil {
+		return Pack{}, err
+	}
+	if inAmps {
+		energyNow *= pack.Voltage
+		energyFull *= pack.Voltage
+		energyMax *= pack.Voltage
+		power *= pack.Voltage
+	}
+	pack.EnergyFull = energyFull
+	pack.EnergyMax = energyMax
+	pack.Power = math.Abs(power)
+	pack.EnergyNow = energyNow
+	if !energyNowProvided {
+		pack.EnergyNow = energyFull * float64(pack.Capacity) / 100
+	}
+	pack.StartThreshold = readInt(path, "charge_control_start_threshold", "charge_start_threshold")
+	pack.EndThreshold = readInt(path, "charge_control_end_threshold", "charge_stop_threshold")
+	return pack, nil
+}
+
+// combine sums the packs into a single battery like barista's battery.All().
+func combine(packs []Pack) battery.Info {
+	if len(packs) == 0 {
+		return battery.Info{Status: battery.Disconnected}
+	}
+	var all battery.Info
+	var techs []string
+	var voltEnergySum float64
+	for _, pack := range packs {
+		all.EnergyFull += pack.EnergyFull
+		all.EnergyMax += pack.EnergyMax
+		all.EnergyNow += pack.EnergyNow
+		if pack.Technology != "" {
+			techs = append(techs, pack.Technology)
+		}
+		voltEnergySum += pack.Voltage * pack.EnergyNow
+		// A discharging pack can be charging another, so the status
+		// follows the direction of the combined power.
+		signedPower := all.SignedPower() + pack.SignedPower()
+		all.Power = math.Abs(signedPower)
+		switch {
+		case all.Status == battery.Charging && signedPower < 0:
+			all.Status = battery.Discharging
+		case all.Status == battery.Discharging && signedPower > 0:
+			all.Status = battery.Charging
+		case all.Status != battery.Charging && all.Status != battery.Discharging && pack.Status != battery.Unknown:
+			all.Status = pack.Status
+		}
+	}
+	if all.EnergyNow > 0 {
+		all.Voltage = voltEnergySum / all.EnergyNow
+	}
+	if all.EnergyFull > 0 {
+		all.Capacity = int(all.EnergyNow * 100 / all.EnergyFull)
+	}
+	all.Technology = strings.Join(techs, ",")
+	return all
+}
+
+func status(value string) battery.Status {
+	switch s := battery.Status(value); s {
+	case battery.Full, battery.Charging, battery.Discharging, battery.NotCharging:
+		return s
+	default:
+		return battery.Unknown
+	}
+}
+
+func fromMicro(value string) float64 {
+	micros, _ := strconv.Atoi(value)
+	return float64(micros) / 1e6
+}
+
+func readString(path string) string {
+	data, _ := os.ReadFile(path)
+	return strings.TrimSpace(string(data))
+}
+
+// readInt returns the value of the first of the given files in dir that
+// exists, or 0 if none does.
+func readInt(dir string, names ...string) int {
+	for _, name := range names {
+		if value, err := strconv.Atoi(readString(filepath.Join(dir, name))); err == nil {
+			return value
+		}
+	}
+	return 0
+}
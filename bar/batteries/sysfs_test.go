@@ -0,0 +1,95 @@
+package batteries
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"barista.run/modules/battery"
+)
+
+// fakeSysfs creates a power_supply class with a battery reporting energy, a
+// battery reporting charge, a mouse battery and disconnected mains power.
+func fakeSysfs(t *testing.T) string {
+	root := t.TempDir()
+	files := map[string]string{
+		"class/power_supply/BAT0/type": "Battery\n",
+		"class/power_supply/BAT0/uevent": "POWER_SUPPLY_NAME=BAT0\n" +
+			"POWER_SUPPLY_STATUS=Discharging\n" +
+			"POWER_SUPPLY_TECHNOLOGY=Li-ion\n" +
+			"POWER_SUPPLY_CYCLE_COUNT=120\n" +
+			"POWER_SUPPLY_VOLTAGE_NOW=12000000\n" +
+			"POWER_SUPPLY_POWER_NOW=10000000\n" +
+			"POWER_SUPPLY_ENERGY_FULL_DESIGN=60000000\n" +
+			"POWER_SUPPLY_ENERGY_FULL=50000000\n" +
+			"POWER_SUPPLY_ENERGY_NOW=30000000\n" +
+			"POWER_SUPPLY_CAPACITY=60\n",
+		"class/power_supply/BAT0/charge_control_end_threshold": "80\n",
+		"class/power_supply/BAT1/type":                         "Battery\n",
+		"class/power_supply/BAT1/uevent": "POWER_SUPPLY_NAME=BAT1\n" +
+			"POWER_SUPPLY_STATUS=Discharging\n" +
+			"POWER_SUPPLY_VOLTAGE_NOW=10000000\n" +
+			"POWER_SUPPLY_CURRENT_NOW=500000\n" +
+			"POWER_SUPPLY_CHARGE_FULL_DESIGN=2000000\n" +
+			"POWER_SUPPLY_CHARGE_FULL=2000000\n" +
+			"POWER_SUPPLY_CHARGE_NOW=1000000\n",
+		"class/power_supply/BAT1/charge_start_threshold": "40\n",
+		"class/power_supply/BAT1/charge_stop_threshold":  "90\n",
+		"class/power_supply/hid-mouse-battery/type":      "Battery\n",
+		"class/power_supply/hid-mouse-battery/scope":     "Device\n",
+		"class/power_supply/hid-mouse-battery/uevent":    "POWER_SUPPLY_STATUS=Discharging\n",
+		"class/power_supply/AC/type":                     "Mains\n",
+		"class/power_supply/AC/online":                   "0\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func TestRead(t *testing.T) {
+	info, err := Read(fakeSysfs(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.AC {
+		t.Error("got AC online, want offline")
+	}
+	if len(info.Packs) != 2 {
+		t.Fatalf("got %d packs, want BAT0 and BAT1", len(info.Packs))
+	}
+
+	bat0, bat1 := info.Packs[0], info.Packs[1]
+	if bat0.Name != "BAT0" || bat0.EnergyNow != 30 || bat0.Power != 10 || bat0.CycleCount != 120 ||
+		bat0.StartThreshold != 0 || bat0.EndThreshold != 80 {
+		t.Errorf("got BAT0 %+v", bat0)
+	}
+	// Charge in µAh and current in µA are converted using the voltage.
+	if bat1.Name != "BAT1" || bat1.EnergyNow != 10 || bat1.EnergyFull != 20 || bat1.Power != 5 ||
+		bat1.StartThreshold != 40 || bat1.EndThreshold != 90 {
+		t.Errorf("got BAT1 %+v", bat1)
+	}
+
+	near := func(a, b float64) bool { return math.Abs(a-b) < 1e-9 }
+	if info.Status != battery.Discharging || info.EnergyNow != 40 || info.EnergyFull != 70 ||
+		info.Power != 15 || info.Capacity != 57 || !near(info.Voltage, 11.5) || !near(info.Health(), 0.875) {
+		t.Errorf("got combined %+v", info.Info)
+	}
+}
+
+func TestReadNoPowerSupply(t *testing.T) {
+	info, err := Read(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Status != battery.Disconnected || len(info.Packs) != 0 {
+		t.Errorf("got %+v, want disconnected", info)
+	}
+}
@@ -0,0 +1,272 @@
+// Package bluetooth provides a Bluetooth indicator backed by BlueZ over
+// D-Bus, showing the adapter power state and the connected devices with
+// their battery charge.
+package bluetooth
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/base/value"
+	"barista.run/outputs"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// Info is the state of all Bluetooth adapters and their devices.
+type Info struct {
+	// Adapters are sorted by object path, so the first one is usually hci0.
+	Adapters []Adapter
+	// Devices holds all known devices sorted by alias.
+	Devices []Device
+	// Favorite is the configured favorite device, if it is known.
+	Favorite *Device
+
+	conn *dbus.Conn
+}
+
+// Available reports whether there is any adapter.
+func (i Info) Available() bool {
+	return len(i.Adapters) > 0
+}
+
+// Powered reports whether any adapter is powered on.
+func (i Info) Powered() bool {
+	for _, a := range i.Adapters {
+		if a.Powered {
+			return true
+		}
+	}
+	return false
+}
+
+// Connected returns the connected devices.
+func (i Info) Connected() []Device {
+	var connected []Device
+	for _, d := range i.Devices {
+		if d.Connected {
+			connected = append(connected, d)
+		}
+	}
+	return connected
+}
+
+// TogglePower powers all adapters off if any is on, or on otherwise.
+func (i Info) TogglePower() {
+	powered := !i.Powered()
+	for _, a := range i.Adapters {
+		i.call(a.Path, propertiesIface+".Set", adapterIface, "Powered", dbus.MakeVariant(powered))
+	}
+}
+
+// Connect connects to the device.
+func (i Info) Connect(d Device) {
+	i.call(d.Path, deviceIface+".Connect")
+}
+
+// Disconnect disconnects the device.
+func (i Info) Disconnect(d Device) {
+	i.call(d.Path, deviceIface+".Disconnect")
+}
+
+// ToggleFavorite disconnects the favorite device if it is connected, or
+// powers on its adapter and connects to it otherwise.
+func (i Info) ToggleFavorite() {
+	switch {
+	case i.Favorite == nil || i.conn == nil:
+	case i.Favorite.Connected:
+		i.Disconnect(*i.Favorite)
+	default:
+		favorite := *i.Favorite
+		go func() {
+			// Connecting fails while the adapter is still powering on.
+			if err := i.powerOn(favorite.Adapter); err != nil {
+				log.Printf("failed to power on %s: %v", favorite.Adapter, err)
+				return
+			}
+			i.callWait(favorite.Path, deviceIface+".Connect")
+		}()
+	}
+}
+
+// powerOnTimeout is how long to wait for an adapter to report being powered
+// on.
+const powerOnTimeout = 5 * time.Second
+
+// powerOn powers the adapter on, if it is not yet, and waits until it
+// reports being powered.
+func (i Info) powerOn(adapter dbus.ObjectPath) error {
+	for _, a := range i.Adapters {
+		if a.Path == adapter && a.Powered {
+			return nil
+		}
+	}
+	obj := i.conn.Object(service, adapter)
+	call := obj.Call(propertiesIface+".Set", 0, adapterIface, "Powered", dbus.MakeVariant(true))
+	if call.Err != nil {
+		return call.Err
+	}
+	deadline := time.Now().Add(powerOnTimeout)
+	for {
+		powered, err := obj.GetProperty(adapterIface + ".Powered")
+		if err != nil {
+			return err
+		}
+		if boolean(powered) {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("not powered after %s", powerOnTimeout)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// call calls a BlueZ method in the background, since connecting can take
+// several seconds.
+func (i Info) call(path dbus.ObjectPath, method string, args ...interface{}) {
+	if i.conn == nil {
+		return
+	}
+	go i.callWait(path, method, args...)
+}
+
+// callWait calls a BlueZ method and logs failures.
+func (i Info) callWait(path dbus.ObjectPath, method string, args ...interface{}) {
+	call := i.conn.Object(service, path).Call(method, 0, args...)
+	if call.Err != nil {
+		log.Printf("failed to call %s on %s: %v", method, path, call.Err)
+	}
+}
+
+// Module represents a Bluetooth barista module.
+type Module struct {
+	bus        func(...dbus.ConnOption) (*dbus.Conn, error)
+	favorite   string
+	outputFunc value.Value // of func(Info) bar.Output
+}
+
+// New constructs a Bluetooth module for the system bus.
+func New() *Module {
+	m := &Module{bus: dbus.ConnectSystemBus}
+	m.Output(func(i Info) bar.Output {
+		if !i.Available() {
+			return nil
+		}
+		var out *bar.Segment
+		switch connected := i.Connected(); {
+		case !i.Powered():
+			out = outputs.Text("off")
+		case len(connected) == 0:
+			out = outputs.Text("on")
+		default:
+			names := make([]string, len(connected))
+			for n, d := range connected {
+				names[n] = d.Alias
+				if d.Battery >= 0 {
+					names[n] += fmt.Sprintf(" %d%%", d.Battery)
+				}
+			}
+			out = outputs.Text(strings.Join(names, ", "))
+		}
+		return out.OnClick(func(e bar.Event) {
+			switch e.Button {
+			case bar.ButtonLeft:
+				i.TogglePower()
+			case bar.ButtonRight:
+				i.ToggleFavorite()
+			}
+		})
+	})
+	return m
+}
+
+// Bus sets how to connect to the bus BlueZ is on, dbus.ConnectSystemBus by
+// default. Connecting to a session bus exporting fake BlueZ objects allows
+// testing.
+func (m *Module) Bus(connect func(...dbus.ConnOption) (*dbus.Conn, error)) *Module {
+	m.bus = connect
+	return m
+}
+
+// Favorite sets the address of the device to connect to with
+// Info.ToggleFavorite.
+func (m *Module) Favorite(address string) *Module {
+	m.favorite = address
+	return m
+}
+
+// Output sets the output format for the module.
+func (m *Module) Output(outputFunc func(Info) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+// Stream starts the module.
+func (m *Module) Stream(sink bar.Sink) {
+	conn, err := m.bus()
+	if sink.Error(err) {
+		return
+	}
+	defer conn.Close()
+
+	// Any change of BlueZ objects or properties, or BlueZ (re)starting,
+	// triggers reading all objects again.
+	signals := make(chan *dbus.Signal, 10)
+	conn.Signal(signals)
+	err = conn.AddMatchSignal(dbus.WithMatchSender(service))
+	if err == nil {
+		err = conn.AddMatchSignal(
+			dbus.WithMatchInterface("org.freedesktop.DBus"),
+			dbus.WithMatchMember("NameOwnerChanged"),
+			dbus.WithMatchArg(0, service),
+		)
+	}
+	if sink.Error(err) {
+		return
+	}
+
+	info, err := m.read(conn)
+	outputFunc := m.outputFunc.Get().(func(Info) bar.Output)
+	nextOutputFunc, done := m.outputFunc.Subscribe()
+	defer done()
+	for {
+		if sink.Error(err) {
+			return
+		}
+		sink.Output(outputFunc(info))
+		select {
+		case _, ok := <-signals:
+			if !ok {
+				sink.Error(errors.New("lost connection to the bus"))
+				return
+			}
+			// Drain signals that arrived in the meantime, e.g. all
+			// properties of a device that just connected.
+			for len(signals) > 0 {
+				<-signals
+			}
+			info, err = m.read(conn)
+		case <-nextOutputFunc:
+			outputFunc = m.outputFunc.Get().(func(Info) bar.Output)
+		}
+	}
+}
+
+func (m *Module) read(conn *dbus.Conn) (Info, error) {
+	adapters, devices, err := getObjects(conn)
+	if err != nil {
+		return Info{}, err
+	}
+	info := Info{Adapters: adapters, Devices: devices, conn: conn}
+	for n := range devices {
+		if m.favorite != "" && strings.EqualFold(devices[n].Address, m.favorite) {
+			info.Favorite = &devices[n]
+		}
+	}
+	return info, nil
+}
@@ -0,0 +1,195 @@
+package bluetooth
+
+import (
+	"bufio"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"barista.run/bar"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const (
+	adapterPath = dbus.ObjectPath("/org/bluez/hci0")
+	devicePath  = dbus.ObjectPath("/org/bluez/hci0/dev_00_11_22_33_44_55")
+)
+
+// startBus starts a private session bus and returns its address.
+func startBus(t *testing.T) string {
+	if _, err := exec.LookPath("dbus-daemon"); err != nil {
+		t.Skip("dbus-daemon not found")
+	}
+	cmd := exec.Command("dbus-daemon", "--session", "--nofork", "--print-address",
+		"--address=unix:path="+filepath.Join(t.TempDir(), "bus"))
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	})
+	address, err := bufio.NewReader(stdout).ReadString('\n')
+	if err != nil {
+		t.Fatalf("failed to read bus address: %v", err)
+	}
+	return strings.TrimSpace(address)
+}
+
+// fakeBluez exports an adapter that powers on with a delay, like a real
+// controller, and a device that records whether the adapter was powered when
+// connecting.
+type fakeBluez struct {
+	conn *dbus.Conn
+
+	mu                sync.Mutex
+	powered           bool
+	connected         bool
+	poweredAtConnect  bool
+	connectsAttempted int
+}
+
+func newFakeBluez(t *testing.T, address string) *fakeBluez {
+	conn, err := dbus.Connect(address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	f := &fakeBluez{conn: conn}
+	for _, export := range []struct {
+		path    dbus.ObjectPath
+		iface   string
+		methods map[string]interface{}
+	}{
+		{"/", objectManager, map[string]interface{}{"GetManagedObjects": f.getManagedObjects}},
+		{adapterPath, propertiesIface, map[string]interface{}{"Get": f.get, "Set": f.set}},
+		{devicePath, deviceIface, map[string]interface{}{"Connect": f.connect}},
+	} {
+		if err := conn.ExportMethodTable(export.methods, export.path, export.iface); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := conn.RequestName(service, dbus.NameFlagDoNotQueue); err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func (f *fakeBluez) getManagedObjects() (managedObjects, *dbus.Error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return managedObjects{
+		adapterPath: {adapterIface: {
+			"Address": dbus.MakeVariant("00:00:00:00:00:01"),
+			"Alias":   dbus.MakeVariant("laptop"),
+			"Powered": dbus.MakeVariant(f.powered),
+		}},
+		devicePath: {deviceIface: {
+			"Adapter":   dbus.MakeVariant(adapterPath),
+			"Address":   dbus.MakeVariant("00:11:22:33:44:55"),
+			"Alias":     dbus.MakeVariant("headphones"),
+			"Paired":    dbus.MakeVariant(true),
+			"Connected": dbus.MakeVariant(f.connected),
+		}},
+	}, nil
+}
+
+func (f *fakeBluez) get(iface, name string) (dbus.Variant, *dbus.Error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if iface != adapterIface || name != "Powered" {
+		return dbus.Variant{}, dbus.MakeFailedError(dbus.ErrMsgInvalidArg)
+	}
+	return dbus.MakeVariant(f.powered), nil
+}
+
+func (f *fakeBluez) set(iface, name string, value dbus.Variant) *dbus.Error {
+	if iface != adapterIface || name != "Powered" {
+		return dbus.MakeFailedError(dbus.ErrMsgInvalidArg)
+	}
+	powered, _ := value.Value().(bool)
+	time.AfterFunc(300*time.Millisecond, func() {
+		f.mu.Lock()
+		f.powered = powered
+		f.mu.Unlock()
+		f.changed(adapterPath, adapterIface, "Powered", powered)
+	})
+	return nil
+}
+
+func (f *fakeBluez) connect() *dbus.Error {
+	f.mu.Lock()
+	f.connectsAttempted++
+	f.poweredAtConnect = f.powered
+	if !f.powered {
+		f.mu.Unlock()
+		return dbus.NewError("org.bluez.Error.NotReady", []interface{}{"Resource Not Ready"})
+	}
+	f.connected = true
+	f.mu.Unlock()
+	f.changed(devicePath, deviceIface, "Connected", true)
+	return nil
+}
+
+func (f *fakeBluez) changed(path dbus.ObjectPath, iface, name string, value interface{}) {
+	_ = f.conn.Emit(path, propertiesIface+".PropertiesChanged",
+		iface, map[string]dbus.Variant{name: dbus.MakeVariant(value)}, []string{})
+}
+
+func TestToggleFavoritePowersOnFirst(t *testing.T) {
+	address := startBus(t)
+	fake := newFakeBluez(t, address)
+
+	infos := make(chan Info, 100)
+	m := New().
+		Bus(func(opts ...dbus.ConnOption) (*dbus.Conn, error) {
+			return dbus.Connect(address, opts...)
+		}).
+		Favorite("00:11:22:33:44:55").
+		Output(func(i Info) bar.Output {
+			infos <- i
+			return nil
+		})
+	go m.Stream(bar.Sink(func(bar.Output) {}))
+
+	// waitFor returns the first state matching the condition.
+	waitFor := func(what string, cond func(Info) bool) Info {
+		t.Helper()
+		timeout := time.After(10 * time.Second)
+		for {
+			select {
+			case i := <-infos:
+				if cond(i) {
+					return i
+				}
+			case <-timeout:
+				t.Fatalf("timed out waiting for %s", what)
+			}
+		}
+	}
+
+	info := waitFor("favorite", func(i Info) bool {
+		return i.Available() && i.Favorite != nil
+	})
+	if info.Powered() || info.Favorite.Connected || info.Favorite.Alias != "headphones" {
+		t.Fatalf("got initial state %+v", info)
+	}
+	info.ToggleFavorite()
+	waitFor("connected favorite", func(i Info) bool {
+		return i.Powered() && i.Favorite != nil && i.Favorite.Connected
+	})
+
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	if fake.connectsAttempted != 1 || !fake.poweredAtConnect {
+		t.Errorf("connected %d times, powered at the last attempt: %v", fake.connectsAttempted, fake.poweredAtConnect)
+	}
+}
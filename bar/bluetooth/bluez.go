@@ -0,0 +1,103 @@
+package bluetooth
+
+import (
+	"errors"
+	"sort"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const (
+	service         = "org.bluez"
+	adapterIface    = "org.bluez.Adapter1"
+	deviceIface     = "org.bluez.Device1"
+	batteryIface    = "org.bluez.Battery1"
+	objectManager   = "org.freedesktop.DBus.ObjectManager"
+	propertiesIface = "org.freedesktop.DBus.Properties"
+)
+
+// Adapter is a local Bluetooth controller.
+type Adapter struct {
+	Path    dbus.ObjectPath
+	Address string
+	Alias   string
+	Powered bool
+}
+
+// Device is a remote device known to an adapter.
+type Device struct {
+	Path      dbus.ObjectPath
+	Adapter   dbus.ObjectPath
+	Address   string
+	Alias     string
+	Paired    bool
+	Connected bool
+	// Battery is the charge in percent reported by the device, or -1 if
+	// it does not report one.
+	Battery int
+}
+
+// managedObjects is the reply of ObjectManager.GetManagedObjects: the
+// properties of each interface of each object.
+type managedObjects map[dbus.ObjectPath]map[string]map[string]dbus.Variant
+
+// getObjects returns all adapters and devices of BlueZ, sorted by path and
+// alias. If BlueZ is not running, both are empty.
+func getObjects(conn *dbus.Conn) ([]Adapter, []Device, error) {
+	var objects managedObjects
+	err := conn.Object(service, "/").Call(objectManager+".GetManagedObjects", 0).Store(&objects)
+	var dbusErr dbus.Error
+	if errors.As(err, &dbusErr) && dbusErr.Name == "org.freedesktop.DBus.Error.ServiceUnknown" {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	var adapters []Adapter
+	var devices []Device
+	for path, ifaces := range objects {
+		if props, ok := ifaces[adapterIface]; ok {
+			adapters = append(adapters, Adapter{
+				Path:    path,
+				Address: str(props["Address"]),
+				Alias:   str(props["Alias"]),
+				Powered: boolean(props["Powered"]),
+			})
+		}
+		if props, ok := ifaces[deviceIface]; ok {
+			dev := Device{
+				Path:      path,
+				Address:   str(props["Address"]),
+				Alias:     str(props["Alias"]),
+				Paired:    boolean(props["Paired"]),
+				Connected: boolean(props["Connected"]),
+				Battery:   -1,
+			}
+			dev.Adapter, _ = props["Adapter"].Value().(dbus.ObjectPath)
+			if battery, ok := ifaces[batteryIface]["Percentage"].Value().(byte); ok {
+				dev.Battery = int(battery)
+			}
+			devices = append(devices, dev)
+		}
+	}
+	sort.Slice(adapters, func(i, j int) bool {
+		return adapters[i].Path < adapters[j].Path
+	})
+	sort.Slice(devices, func(i, j int) bool {
+		if devices[i].Alias != devices[j].Alias {
+			return devices[i].Alias < devices[j].Alias
+		}
+		return devices[i].Path < devices[j].Path
+	})
+	return adapters, devices, nil
+}
+
+func str(v dbus.Variant) string {
+	s, _ := v.Value().(string)
+	return s
+}
+
+func boolean(v dbus.Variant) bool {
+	b, _ := v.Value().(bool)
+	return b
+}
@@ -0,0 +1,168 @@
+package certinfo
+
+import (
+	"fmt"
+	"github.com/tionis/i3-tools/bar/certwatch"
+	"golang.org/x/crypto/ssh"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Cert is a ssh certificate together with the file it was loaded from.
+// If the file could not be parsed, Certificate is nil and Err is set.
+//
+// Cert is passed to the format template, so besides the methods below all
+// fields of ssh.Certificate (KeyId, ValidPrincipals, Serial, ...) can be used.
+type Cert struct {
+	Path string
+	*ssh.Certificate
+	Err error
+}
+
+// Name returns the file name of the certificate.
+func (c Cert) Name() string {
+	return filepath.Base(c.Path)
+}
+
+// Validity returns the time passed since the certificate became valid and the
+// time remaining until it expires, e.g. "3.2h/4.8h".
+func (c Cert) Validity() string {
+	timePassed, timeRemaining := validity(c.Certificate)
+	return fmt.Sprintf("%s/%s", certwatch.Duration(timePassed), certwatch.Duration(timeRemaining))
+}
+
+// Remaining returns the time remaining until the certificate expires.
+func (c Cert) Remaining() string {
+	_, timeRemaining := validity(c.Certificate)
+	return certwatch.Duration(timeRemaining)
+}
+
+// RemainingFraction returns the fraction of the validity period that is
+// left, from 1 for a certificate that just became valid to 0 once expired.
+func (c Cert) RemainingFraction() float64 {
+	return certwatch.RemainingFraction(validity(c.Certificate))
+}
+
+// Principals returns the principals the certificate is valid for as a comma
+// separated list.
+func (c Cert) Principals() string {
+	if len(c.ValidPrincipals) == 0 {
+		return "any"
+	}
+	return strings.Join(c.ValidPrincipals, ",")
+}
+
+// Kind returns "user" or "host" depending on the certificate type.
+func (c Cert) Kind() string {
+	switch c.CertType {
+	case ssh.UserCert:
+		return "user"
+	case ssh.HostCert:
+		return "host"
+	default:
+		return fmt.Sprintf("unknown(%d)", c.CertType)
+	}
+}
+
+// CAFingerprint returns the SHA256 fingerprint of the signing CA.
+func (c Cert) CAFingerprint() string {
+	return ssh.FingerprintSHA256(c.SignatureKey)
+}
+
+// Options returns the critical options of the certificate as a space
+// separated list of key=value pairs.
+func (c Cert) Options() string {
+	return renderTuples(c.CriticalOptions)
+}
+
+// Details returns a multi line description of the certificate, similar to
+// the output of ssh-keygen -L.
+func (c Cert) Details() string {
+	if c.Err != nil {
+		return fmt.Sprintf("%s: %v", c.Path, c.Err)
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "Path: %s\n", c.Path)
+	fmt.Fprintf(&b, "Type: %s certificate\n", c.Kind())
+	fmt.Fprintf(&b, "Key ID: %s\n", c.KeyId)
+	fmt.Fprintf(&b, "Serial: %d\n", c.Serial)
+	fmt.Fprintf(&b, "Signing CA: %s %s\n", c.SignatureKey.Type(), c.CAFingerprint())
+	fmt.Fprintf(&b, "Valid: from %s to %s\n", renderTimestamp(c.ValidAfter), renderTimestamp(c.ValidBefore))
+	fmt.Fprintf(&b, "Principals: %s\n", c.Principals())
+	fmt.Fprintf(&b, "Critical Options: %s\n", orNone(c.Options()))
+	fmt.Fprintf(&b, "Extensions: %s", orNone(renderTuples(c.Extensions)))
+	return b.String()
+}
+
+// validity returns the time passed since the certificate became valid and
+// the time remaining until it expires. Certificates valid forever remain
+// valid for the longest duration.
+func validity(cert *ssh.Certificate) (timePassed, timeRemaining time.Duration) {
+	now := uint64(time.Now().Unix())
+	if now > cert.ValidAfter {
+		timePassed = seconds(now - cert.ValidAfter)
+	}
+	if now < cert.ValidBefore {
+		timeRemaining = seconds(cert.ValidBefore - now)
+	}
+	return timePassed, timeRemaining
+}
+
+func seconds(s uint64) time.Duration {
+	if s > uint64(math.MaxInt64/time.Second) {
+		return math.MaxInt64
+	}
+	return time.Duration(s) * time.Second
+}
+
+func renderTimestamp(timestamp uint64) string {
+	switch timestamp {
+	case 0:
+		return "always"
+	case ssh.CertTimeInfinity:
+		return "forever"
+	default:
+		return time.Unix(int64(timestamp), 0).Format("2006-01-02 15:04:05")
+	}
+}
+
+func renderTuples(tuples map[string]string) string {
+	keys := make([]string, 0, len(tuples))
+	for key := range tuples {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for i, key := range keys {
+		if tuples[key] != "" {
+			keys[i] = key + "=" + tuples[key]
+		}
+	}
+	return strings.Join(keys, " ")
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "(none)"
+	}
+	return s
+}
+
+func parseCertFile(certPath string) (*ssh.Certificate, error) {
+	certBytes, err := os.ReadFile(certPath)
+	if err != nil {
+		return nil, err
+	}
+	certAsKey, _, _, _, err := ssh.ParseAuthorizedKey(certBytes)
+	if err != nil {
+		return nil, err
+	}
+	cert, ok := certAsKey.(*ssh.Certificate)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a certificate", certPath)
+	}
+	return cert, nil
+}
@@ -5,183 +5,241 @@ import (
 	"barista.run/base/value"
 	"barista.run/colors"
 	"barista.run/outputs"
-	"fmt"
-	"github.com/fsnotify/fsnotify"
-	"github.com/multiplay/go-cticker"
+	"context"
+	"github.com/tionis/i3-tools/bar/certwatch"
 	"golang.org/x/crypto/ssh"
 	"log"
 	"os"
-	"path"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
 	"time"
 )
 
-// Module represents a yubikey barista module that shows an indicator whenever
-// the plugged-in yubikey is waiting for user input.
+// Module represents a certinfo barista module that shows the remaining
+// validity of one or more ssh certificates.
 type Module struct {
-	certPath       string
-	outputFunc     value.Value // of func(bool, bool) bar.Output
-	ticker         *cticker.Ticker
-	tickerAccuracy int
-	cert           *ssh.Certificate
-	err            error
-	format         string
+	patterns   []string
+	outputFunc value.Value // of func([]Cert) bar.Output
+	format     *template.Template
+	formatErr  error
+	showAll    bool
+	terminal   string
+	renewer    *renewer
 }
 
-func (m *Module) parseCert() {
-	m.cert, m.err = parseCertFile(m.certPath)
-}
-
-// ForPath constructs a yubikey module with the given path to the gpg keyring.
-func ForPath(certPath, format string) *Module {
-	m := &Module{
-		certPath:       certPath,
-		ticker:         cticker.New(time.Minute, time.Second),
-		tickerAccuracy: 60,
-		format:         format,
-	}
-
-	m.Output(func() bar.Output {
-		if m.cert == nil {
-			m.parseCert()
+// loadCerts expands all configured patterns and parses every matching file.
+// Certificates are sorted by expiry, soonest first, followed by the files that
+// could not be parsed, so that a broken file does not hide the valid ones.
+func (m *Module) loadCerts() []Cert {
+	var certs []Cert
+	certwatch.Glob(m.patterns, func(certPath string, err error) {
+		var cert *ssh.Certificate
+		if err == nil {
+			cert, err = parseCertFile(certPath)
 		}
-		if m.err != nil {
-			return outputs.Error(m.err)
-		}
-
-		now := uint64(time.Now().Unix())
-		var timePassed, timeRemaining uint64
-		if now < m.cert.ValidAfter {
-			timePassed = 0
-		} else {
-			timePassed = now - m.cert.ValidAfter
-		}
-		if now >= m.cert.ValidBefore {
-			timeRemaining = 0
-		} else {
-			timeRemaining = m.cert.ValidBefore - now
+		certs = append(certs, Cert{Path: certPath, Certificate: cert, Err: err})
+	})
+	sort.SliceStable(certs, func(i, j int) bool {
+		if certs[i].Err != nil || certs[j].Err != nil {
+			return certs[i].Err == nil && certs[j].Err != nil
 		}
+		return certs[i].ValidBefore < certs[j].ValidBefore
+	})
+	return certs
+}
 
-		if timeRemaining < 120 || timePassed < 120 {
-			if m.tickerAccuracy != 1 {
-				m.ticker = cticker.New(time.Second, time.Millisecond*100)
-				m.tickerAccuracy = 1
-			}
-		} else if timeRemaining <= 60*60 || timePassed <= 60*60 {
-			if m.tickerAccuracy != 60 {
-				m.ticker = cticker.New(time.Minute, time.Second)
-				m.tickerAccuracy = 60
-			}
-		} else if timeRemaining <= 24*60*60 || timePassed <= 24*60*60 {
-			if m.tickerAccuracy != 60 {
-				m.ticker = cticker.New(time.Hour, time.Minute)
-				m.tickerAccuracy = 60 * 60
-			}
-		} else {
-			if m.tickerAccuracy != 60*60 {
-				m.ticker = cticker.New(time.Hour, time.Minute)
-				m.tickerAccuracy = 60 * 60
-			}
-		}
+// ForPaths constructs a certinfo module for the given certificate paths.
+// Paths may contain glob patterns, files that do not exist (yet) are ignored
+// until they appear. The format is a text/template executed with a Cert,
+// e.g. "[{{.Validity}}]" or "{{.KeyId}} ({{.Principals}}) {{.Remaining}}".
+func ForPaths(format string, paths ...string) *Module {
+	m := &Module{patterns: paths}
+	m.format, m.formatErr = template.New("certinfo").Parse(format)
 
-		out := outputs.Textf(m.format, fmt.Sprintf("%s/%s", renderTime(timePassed), renderTime(timeRemaining)))
-		if timeRemaining == 0 {
-			out.Color(colors.Scheme("bad"))
-			out.Urgent(true)
-		} else {
-			remainingPercentage := 1 - float64(timePassed)/float64(timePassed+timeRemaining)
-			if remainingPercentage < 0.25 {
-				out.Color(colors.Scheme("bad"))
-			} else if remainingPercentage < 0.5 {
-				out.Color(colors.Scheme("degraded"))
-			} else {
-				out.Color(colors.Scheme("good"))
-			}
-		}
-		return out
+	m.OutputCerts(func(certs []Cert) bar.Output {
+		return certwatch.Output(certs, m.showAll, m.renderCert)
 	})
 	return m
 }
 
-// New constructs a new yubikey module using the default paths for the u2f
-// pending file and gpg keyring.
+// ForPath constructs a certinfo module for a single certificate path.
+func ForPath(certPath, format string) *Module {
+	return ForPaths(format, certPath)
+}
+
+// New constructs a new certinfo module watching all user certificates
+// in ~/.ssh (rsa, ecdsa, ed25519 and their sk-* variants).
 func New(format string) *Module {
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
 		log.Fatal(err)
 	}
-	return ForPath(path.Join(homeDir, ".ssh", "id_ed25519-cert.pub"), format)
+	return ForPaths(format, filepath.Join(homeDir, ".ssh", "id_*-cert.pub"))
 }
 
-// Output sets the output format for the module.
+// ShowAll configures the module to display every certificate instead of
+// only the one expiring soonest.
+func (m *Module) ShowAll(showAll bool) *Module {
+	m.showAll = showAll
+	return m
+}
+
+// DetailsInTerminal configures the module to show the certificate details
+// by running ssh-keygen -L in the given terminal emulator when clicked,
+// instead of sending a desktop notification.
+func (m *Module) DetailsInTerminal(terminalEmulator string) *Module {
+	m.terminal = terminalEmulator
+	return m
+}
+
+// Renew configures a command (run with sh -c) that renews a certificate once
+// less than the given fraction of its validity remains, e.g. 0.25. The path
+// of the certificate is passed in $SSH_CERT_PATH. Failed renewals are retried
+// with exponential backoff, a right click triggers a renewal manually.
+func (m *Module) Renew(command string, threshold float64) *Module {
+	m.renewer = newRenewer(command, threshold)
+	return m
+}
+
+// Output sets an output that does not depend on the certificates. It is
+// kept for callers of the single certificate module, use OutputCerts to
+// render the loaded certificates.
 func (m *Module) Output(outputFunc func() bar.Output) *Module {
+	return m.OutputCerts(func([]Cert) bar.Output {
+		return outputFunc()
+	})
+}
+
+// OutputCerts sets the output format for the module. The certificates are
+// sorted by expiry, soonest first, followed by the files that could not be
+// parsed.
+func (m *Module) OutputCerts(outputFunc func([]Cert) bar.Output) *Module {
 	m.outputFunc.Set(outputFunc)
 	return m
 }
 
-// Stream starts the module.
-func (m *Module) Stream(sink bar.Sink) {
-	watcher, err := fsnotify.NewWatcher()
-	if err != nil {
-		log.Println(fmt.Errorf("failed to create watcher: %w", err))
-		return
+func (m *Module) renderCert(cert Cert) *bar.Segment {
+	if cert.Err != nil {
+		return outputs.Errorf("%s: %v", filepath.Base(cert.Path), cert.Err)
 	}
-	err = watcher.Add(m.certPath)
-	if err != nil {
-		log.Println("cert path: ", m.certPath)
-		log.Println(fmt.Errorf("failed to add file to watcher: %w", err))
-		return
+	if m.formatErr != nil {
+		return outputs.Error(m.formatErr)
 	}
-	defer func(watcher *fsnotify.Watcher) {
-		err := watcher.Close()
-		if err != nil {
-			log.Printf("failed to close watcher: %v", err)
+	var text strings.Builder
+	if err := m.format.Execute(&text, cert); err != nil {
+		return outputs.Error(err)
+	}
+	failed := false
+	if m.renewer != nil {
+		var status string
+		status, failed = m.renewer.status(cert.Path)
+		if status != "" {
+			text.WriteString(" " + status)
 		}
-	}(watcher)
-	outputFunc := m.outputFunc.Get().(func() bar.Output)
-	quit := make(chan struct{})
-	sink.Output(outputFunc())
-
-	for {
-		select {
-		case <-m.ticker.C:
-			sink.Output(outputFunc())
-		case event, ok := <-watcher.Events:
-			if !ok {
-				return
-			}
-			if event.Op == fsnotify.Write || event.Op == fsnotify.Create {
-				m.parseCert()
-				sink.Output(outputFunc())
+	}
+	out := outputs.Text(text.String())
+	out.OnClick(func(e bar.Event) {
+		switch e.Button {
+		case bar.ButtonLeft:
+			m.showDetails(cert)
+		case bar.ButtonRight:
+			if m.renewer != nil {
+				m.renewer.renew(cert.Path)
 			}
-		case <-quit:
-			m.ticker.Stop()
-			return
 		}
+	})
+	remainingFraction := cert.RemainingFraction()
+	if remainingFraction == 0 || failed {
+		out.Color(colors.Scheme("bad"))
+		out.Urgent(true)
+	} else {
+		out.Color(colors.Scheme(certwatch.ColorScheme(remainingFraction)))
 	}
+	return out
 }
 
-func renderTime(seconds uint64) string {
-	if seconds <= 0 {
-		return "expired"
-	} else if seconds < 60 {
-		return fmt.Sprintf("%ds", seconds)
-	} else if seconds < 60*60 {
-		return fmt.Sprintf("%dm", seconds/60)
-	} else if seconds < 24*60*60 {
-		return fmt.Sprintf("%.1fh", float64(seconds)/60/60)
+// showDetails displays the full certificate details either as a desktop
+// notification or in a terminal.
+func (m *Module) showDetails(cert Cert) {
+	var cmd *exec.Cmd
+	if m.terminal != "" {
+		cmd = exec.Command(m.terminal, "-e", "sh", "-c", `ssh-keygen -L -f "$1"; read -r _`, "sh", cert.Path)
 	} else {
-		return fmt.Sprintf("%.1fd", float64(seconds)/60/60/24)
+		cmd = exec.Command("notify-send", "--app-name=i3-tools", "SSH certificate "+cert.Name(), cert.Details())
+	}
+	if err := cmd.Run(); err != nil {
+		log.Printf("failed to show certificate details: %v", err)
 	}
 }
 
-func parseCertFile(certPath string) (*ssh.Certificate, error) {
-	certBytes, err := os.ReadFile(certPath)
-	if err != nil {
-		return nil, err
+// refreshInterval returns the refresh interval needed by the certificate
+// closest to a validity boundary.
+func refreshInterval(certs []Cert) time.Duration {
+	if len(certs) == 0 {
+		// Poll for certificates that do not exist yet.
+		return time.Minute
 	}
-	certAsKey, _, _, _, err := ssh.ParseAuthorizedKey(certBytes)
-	if err != nil {
-		return nil, err
+	interval := time.Hour
+	for _, cert := range certs {
+		if cert.Err != nil {
+			continue
+		}
+		interval = min(interval, certwatch.Interval(validity(cert.Certificate)))
+	}
+	return interval
+}
+
+// Stream starts the module.
+func (m *Module) Stream(sink bar.Sink) {
+	m.StreamContext(context.Background(), sink)
+}
+
+// StreamContext starts the module and stops it once ctx is cancelled. All
+// resources, including a running renewal, are released before it returns,
+// so the module can be restarted without leaking anything.
+func (m *Module) StreamContext(ctx context.Context, sink bar.Sink) {
+	watcher := certwatch.Watch(m.patterns, time.Minute)
+	defer watcher.Close()
+	outputFunc := m.outputFunc.Get().(func([]Cert) bar.Output)
+	nextOutputFunc, done := m.outputFunc.Subscribe()
+	defer done()
+	var renewed <-chan struct{}
+	if m.renewer != nil {
+		renewed = m.renewer.changed
+		stop := m.renewer.bind(ctx)
+		defer stop()
+	}
+
+	var certs []Cert
+	refresh := func() {
+		certs = m.loadCerts()
+		watcher.Reset(refreshInterval(certs))
+		if m.renewer != nil {
+			for _, cert := range certs {
+				if cert.Err == nil {
+					m.renewer.maybeRenew(cert)
+					break
+				}
+			}
+		}
+		sink.Output(outputFunc(certs))
+	}
+	refresh()
+
+	for {
+		select {
+		case <-watcher.C:
+			refresh()
+		case <-renewed:
+			refresh()
+		case <-nextOutputFunc:
+			outputFunc = m.outputFunc.Get().(func([]Cert) bar.Output)
+			sink.Output(outputFunc(certs))
+		case <-ctx.Done():
+			return
+		}
 	}
-	return certAsKey.(*ssh.Certificate), nil
 }
@@ -0,0 +1,178 @@
+package certinfo
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"barista.run/bar"
+	"golang.org/x/crypto/ssh"
+)
+
+// newCert returns a certificate valid since an hour ago for the given
+// duration.
+func newCert(t *testing.T, keyID string, validFor time.Duration) *ssh.Certificate {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	cert := &ssh.Certificate{
+		Key:         key,
+		CertType:    ssh.UserCert,
+		KeyId:       keyID,
+		ValidAfter:  uint64(now.Add(-time.Hour).Unix()),
+		ValidBefore: uint64(now.Add(validFor).Unix()),
+	}
+	if err := cert.SignCert(rand.Reader, signer); err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+// writeCert writes a new certificate atomically, so the module never reads
+// a partial file.
+func writeCert(t *testing.T, path string) {
+	tmp := filepath.Join(filepath.Dir(path), ".tmp")
+	if err := os.WriteFile(tmp, ssh.MarshalAuthorizedKey(newCert(t, filepath.Base(path), 24*time.Hour)), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStreamContextRestart(t *testing.T) {
+	dir := t.TempDir()
+	counts := make(chan int, 100)
+	m := ForPaths("{{.KeyId}}", filepath.Join(dir, "*-cert.pub")).OutputCerts(func(certs []Cert) bar.Output {
+		n := 0
+		for _, cert := range certs {
+			if cert.Err == nil {
+				n++
+			}
+		}
+		counts <- n
+		return nil
+	})
+	start := func() (stop func()) {
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			m.StreamContext(ctx, bar.Sink(func(bar.Output) {}))
+		}()
+		return func() {
+			cancel()
+			select {
+			case <-done:
+			case <-time.After(5 * time.Second):
+				t.Fatal("StreamContext did not return after cancelling")
+			}
+		}
+	}
+	waitFor := func(want int) {
+		t.Helper()
+		timeout := time.After(5 * time.Second)
+		for {
+			select {
+			case n := <-counts:
+				if n == want {
+					return
+				}
+			case <-timeout:
+				t.Fatalf("timed out waiting for %d certificates", want)
+			}
+		}
+	}
+
+	stop := start()
+	waitFor(0)
+	writeCert(t, filepath.Join(dir, "a-cert.pub"))
+	waitFor(1)
+	stop()
+
+	for len(counts) > 0 {
+		<-counts
+	}
+	writeCert(t, filepath.Join(dir, "b-cert.pub"))
+	select {
+	case n := <-counts:
+		t.Errorf("got output of %d certificates after cancelling", n)
+	case <-time.After(300 * time.Millisecond):
+	}
+
+	stop = start()
+	waitFor(2)
+	writeCert(t, filepath.Join(dir, "c-cert.pub"))
+	waitFor(3)
+	stop()
+}
+
+func TestRenewerBackoffPerCertificate(t *testing.T) {
+	r := newRenewer("exit 1", 0.5)
+	stop := r.bind(context.Background())
+	defer stop()
+	// failed waits until the renewal of the certificate failed.
+	failed := func(path string) {
+		t.Helper()
+		timeout := time.After(5 * time.Second)
+		for {
+			if status, bad := r.status(path); bad {
+				return
+			} else if status == "" {
+				t.Fatalf("renewal of %s did not run", path)
+			}
+			select {
+			case <-r.changed:
+			case <-timeout:
+				t.Fatalf("renewal of %s did not fail", path)
+			}
+		}
+	}
+	due := func(path string) Cert {
+		return Cert{Path: path, Certificate: newCert(t, path, time.Minute)}
+	}
+
+	r.maybeRenew(due("a"))
+	failed("a")
+	r.maybeRenew(due("a"))
+	if status, _ := r.status("a"); status == "renewing..." {
+		t.Error("renewed again despite the backoff")
+	}
+
+	// Another certificate does not inherit the backoff of the first.
+	r.maybeRenew(due("b"))
+	failed("b")
+	r.mu.Lock()
+	failures, backoff := r.failures, time.Until(r.nextAttempt)
+	r.mu.Unlock()
+	if failures != 1 || backoff > renewMinBackoff {
+		t.Errorf("got %d failures and a backoff of %v after the first failure", failures, backoff)
+	}
+}
+
+func TestValidForever(t *testing.T) {
+	cert := Cert{Certificate: &ssh.Certificate{
+		ValidAfter:  uint64(time.Now().Add(-2 * time.Hour).Unix()),
+		ValidBefore: ssh.CertTimeInfinity,
+	}}
+	if got := cert.RemainingFraction(); got < 0.99 {
+		t.Errorf("got remaining fraction %v, want almost 1", got)
+	}
+	if got := refreshInterval([]Cert{cert}); got != time.Hour {
+		t.Errorf("got refresh interval %v, want an hour", got)
+	}
+}
@@ -0,0 +1,165 @@
+package certinfo
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+const (
+	renewTimeout    = 10 * time.Minute
+	renewMinBackoff = time.Minute
+	renewMaxBackoff = time.Hour
+)
+
+// renewer runs the configured renewal command with single-flight protection
+// and exponential backoff after failures.
+type renewer struct {
+	command   string
+	threshold float64
+	changed   chan struct{} // notified whenever the renewal state changes
+
+	wg          sync.WaitGroup
+	mu          sync.Mutex
+	ctx         context.Context
+	running     bool
+	path        string // of the certificate renewed last
+	failures    int
+	lastErr     error
+	nextAttempt time.Time
+}
+
+func newRenewer(command string, threshold float64) *renewer {
+	return &renewer{
+		command:   command,
+		threshold: threshold,
+		changed:   make(chan struct{}, 1),
+	}
+}
+
+// bind ties renewals to ctx. The returned function cancels a running
+// renewal and waits for it to finish.
+func (r *renewer) bind(ctx context.Context) (stop func()) {
+	ctx, cancel := context.WithCancel(ctx)
+	r.mu.Lock()
+	r.ctx = ctx
+	r.mu.Unlock()
+	return func() {
+		cancel()
+		r.wg.Wait()
+	}
+}
+
+// due reports whether the certificate has less than the configured fraction
+// of its validity remaining.
+func (r *renewer) due(cert Cert) bool {
+	if cert.Err != nil {
+		return false
+	}
+	return cert.RemainingFraction() < r.threshold
+}
+
+// maybeRenew starts a renewal of the given certificate if it is due and no
+// backoff is in effect for it.
+func (r *renewer) maybeRenew(cert Cert) {
+	if !r.due(cert) {
+		return
+	}
+	r.mu.Lock()
+	wait := cert.Path == r.path && time.Now().Before(r.nextAttempt)
+	r.mu.Unlock()
+	if !wait {
+		r.renew(cert.Path)
+	}
+}
+
+// renew starts the renewal command in the background unless one is already
+// running or the module is not streaming. The certificate path is passed in
+// $SSH_CERT_PATH.
+func (r *renewer) renew(certPath string) {
+	r.mu.Lock()
+	if r.running || r.ctx == nil || r.ctx.Err() != nil {
+		r.mu.Unlock()
+		return
+	}
+	r.running = true
+	if certPath != r.path {
+		// Failures of another certificate do not apply to this one.
+		r.path = certPath
+		r.lastErr = nil
+		r.failures = 0
+		r.nextAttempt = time.Time{}
+	}
+	ctx, cancel := context.WithTimeout(r.ctx, renewTimeout)
+	r.wg.Add(1)
+	r.mu.Unlock()
+	r.notify()
+
+	go func() {
+		defer r.wg.Done()
+		defer cancel()
+		cmd := exec.CommandContext(ctx, "sh", "-c", r.command)
+		cmd.Env = append(os.Environ(), "SSH_CERT_PATH="+certPath)
+		// Do not wait for children of the shell holding on to the output.
+		cmd.WaitDelay = time.Second
+		output, err := cmd.CombinedOutput()
+		if ctx.Err() == context.Canceled {
+			// The module was stopped, this is not a failed renewal.
+			r.mu.Lock()
+			r.running = false
+			r.mu.Unlock()
+			return
+		}
+		if err != nil {
+			err = fmt.Errorf("%w: %s", err, output)
+			log.Printf("failed to renew %s: %v", certPath, err)
+		}
+
+		r.mu.Lock()
+		r.running = false
+		r.lastErr = err
+		if err != nil {
+			r.failures++
+			backoff := renewMinBackoff << (r.failures - 1)
+			if backoff > renewMaxBackoff || backoff <= 0 {
+				backoff = renewMaxBackoff
+			}
+			r.nextAttempt = time.Now().Add(backoff)
+		} else {
+			// Even on success, do not hammer the signer if the command
+			// did not actually replace the certificate.
+			r.failures = 0
+			r.nextAttempt = time.Now().Add(renewMinBackoff)
+		}
+		r.mu.Unlock()
+		r.notify()
+	}()
+}
+
+// status returns a short description of the renewal state of the given
+// certificate, or an empty string if there is nothing to report.
+func (r *renewer) status(certPath string) (string, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	switch {
+	case certPath != r.path:
+		return "", false
+	case r.running:
+		return "renewing...", false
+	case r.lastErr != nil:
+		return "renewal failed", true
+	default:
+		return "", false
+	}
+}
+
+func (r *renewer) notify() {
+	select {
+	case r.changed <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,193 @@
+// Package certwatch finds certificate files given by glob patterns, signals
+// when they change and renders their validity, for the certinfo and x509info
+// modules.
+package certwatch
+
+import (
+	"log"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/outputs"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Glob expands the patterns and calls load once for every matching file.
+// Invalid patterns are passed to load together with the error.
+func Glob(patterns []string, load func(path string, err error)) {
+	seen := make(map[string]bool)
+	for _, pattern := range patterns {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			load(pattern, err)
+			continue
+		}
+		for _, match := range matches {
+			if !seen[match] {
+				seen[match] = true
+				load(match, nil)
+			}
+		}
+	}
+}
+
+// Interval returns how often a certificate with the given time passed since
+// it became valid and remaining until it expires needs to be refreshed, so
+// that the display stays accurate without waking up every second.
+func Interval(timePassed, timeRemaining time.Duration) time.Duration {
+	if timeRemaining < 2*time.Minute || timePassed < 2*time.Minute {
+		return time.Second
+	} else if timeRemaining <= time.Hour || timePassed <= time.Hour {
+		return time.Minute
+	}
+	return time.Hour
+}
+
+// Output renders the certificate expiring soonest, which comes first, or all
+// certificates if showAll is set.
+func Output[C any](certs []C, showAll bool, render func(C) *bar.Segment) bar.Output {
+	if len(certs) == 0 {
+		return nil
+	}
+	if !showAll {
+		return render(certs[0])
+	}
+	group := outputs.Group()
+	for _, cert := range certs {
+		group.Append(render(cert))
+	}
+	return group
+}
+
+// Watcher signals when a file matching one of the patterns changes or the
+// refresh interval elapses. The directories are watched instead of the files
+// themselves, so that certificates that are created or replaced later are
+// picked up as well. Directories that do not exist (yet) or contain glob
+// patterns themselves are only picked up by the periodic refresh.
+type Watcher struct {
+	// C receives a value whenever the certificates should be reloaded.
+	C <-chan struct{}
+
+	c        chan struct{}
+	patterns []string
+	watcher  *fsnotify.Watcher
+	interval chan time.Duration
+	done     chan struct{}
+	wg       sync.WaitGroup
+}
+
+// Watch starts watching the patterns, refreshing at the given interval
+// until it is reset.
+func Watch(patterns []string, interval time.Duration) *Watcher {
+	c := make(chan struct{}, 1)
+	w := &Watcher{
+		C:        c,
+		c:        c,
+		patterns: patterns,
+		interval: make(chan time.Duration),
+		done:     make(chan struct{}),
+	}
+	var err error
+	if w.watcher, err = fsnotify.NewWatcher(); err != nil {
+		log.Printf("failed to create watcher, polling certificates: %v", err)
+		w.watcher = nil
+	} else {
+		for _, dir := range w.dirs() {
+			if err := w.watcher.Add(dir); err != nil {
+				log.Printf("failed to watch %s: %v", dir, err)
+			}
+		}
+	}
+	w.wg.Add(1)
+	go w.run(interval)
+	return w
+}
+
+// Reset changes the refresh interval.
+func (w *Watcher) Reset(interval time.Duration) {
+	select {
+	case w.interval <- interval:
+	case <-w.done:
+	}
+}
+
+// Close stops the watcher and releases its resources.
+func (w *Watcher) Close() {
+	close(w.done)
+	w.wg.Wait()
+	if w.watcher != nil {
+		if err := w.watcher.Close(); err != nil {
+			log.Printf("failed to close watcher: %v", err)
+		}
+	}
+}
+
+func (w *Watcher) run(interval time.Duration) {
+	defer w.wg.Done()
+	ticker := newTicker(interval)
+	defer ticker.Stop()
+	var events <-chan fsnotify.Event
+	var errors <-chan error
+	if w.watcher != nil {
+		events, errors = w.watcher.Events, w.watcher.Errors
+	}
+	for {
+		select {
+		case <-ticker.C:
+			w.notify()
+		case interval := <-w.interval:
+			ticker.reset(interval)
+		case event, ok := <-events:
+			if !ok {
+				events = nil
+			} else if w.matches(event.Name) {
+				w.notify()
+			}
+		case err, ok := <-errors:
+			if !ok {
+				errors = nil
+			} else {
+				log.Printf("failed to watch certificates: %v", err)
+			}
+		case <-w.done:
+			return
+		}
+	}
+}
+
+func (w *Watcher) notify() {
+	select {
+	case w.c <- struct{}{}:
+	default:
+	}
+}
+
+// dirs returns the directories containing the certificates that can be
+// watched.
+func (w *Watcher) dirs() []string {
+	seen := make(map[string]bool)
+	var dirs []string
+	for _, pattern := range w.patterns {
+		dir := filepath.Dir(pattern)
+		if seen[dir] || strings.ContainsAny(dir, "*?[") {
+			continue
+		}
+		seen[dir] = true
+		dirs = append(dirs, dir)
+	}
+	return dirs
+}
+
+// matches reports whether the given file is one of the watched certificates.
+func (w *Watcher) matches(name string) bool {
+	for _, pattern := range w.patterns {
+		if ok, _ := filepath.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
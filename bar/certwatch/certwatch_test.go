@@ -0,0 +1,116 @@
+package certwatch
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestGlob(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a-cert.pub", "b-cert.pub", "c.pub"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var paths []string
+	var errs []error
+	Glob([]string{
+		filepath.Join(dir, "*-cert.pub"),
+		filepath.Join(dir, "a-cert.pub"),
+		filepath.Join(dir, "missing"),
+		filepath.Join(dir, "["),
+	}, func(path string, err error) {
+		if err != nil {
+			errs = append(errs, err)
+			return
+		}
+		paths = append(paths, filepath.Base(path))
+	})
+	if want := []string{"a-cert.pub", "b-cert.pub"}; !reflect.DeepEqual(paths, want) {
+		t.Errorf("got paths %v, want %v", paths, want)
+	}
+	if len(errs) != 1 || !errors.Is(errs[0], filepath.ErrBadPattern) {
+		t.Errorf("got errors %v, want a bad pattern", errs)
+	}
+}
+
+func TestInterval(t *testing.T) {
+	for _, tc := range []struct {
+		passed, remaining, want time.Duration
+	}{
+		{time.Second, 24 * time.Hour, time.Second},
+		{24 * time.Hour, time.Minute, time.Second},
+		{30 * time.Minute, 24 * time.Hour, time.Minute},
+		{24 * time.Hour, time.Hour, time.Minute},
+		{24 * time.Hour, 2 * time.Hour, time.Hour},
+	} {
+		if got := Interval(tc.passed, tc.remaining); got != tc.want {
+			t.Errorf("Interval(%v, %v) = %v, want %v", tc.passed, tc.remaining, got, tc.want)
+		}
+	}
+}
+
+// wait reports whether the watcher signals within the timeout.
+func wait(w *Watcher, timeout time.Duration) bool {
+	select {
+	case <-w.C:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+func TestWatcherFiles(t *testing.T) {
+	dir := t.TempDir()
+	w := Watch([]string{filepath.Join(dir, "*-cert.pub")}, time.Hour)
+	defer w.Close()
+
+	if err := os.WriteFile(filepath.Join(dir, "other"), nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if wait(w, 200*time.Millisecond) {
+		t.Error("signalled for a file that does not match")
+	}
+	if err := os.WriteFile(filepath.Join(dir, "id-cert.pub"), nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if !wait(w, 5*time.Second) {
+		t.Error("no signal for a new certificate")
+	}
+}
+
+func TestWatcherMissingDirectory(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "missing")
+	w := Watch([]string{filepath.Join(dir, "*-cert.pub")}, time.Hour)
+	defer w.Close()
+	w.Reset(10 * time.Millisecond)
+	if !wait(w, 5*time.Second) {
+		t.Error("no periodic refresh without a watchable directory")
+	}
+}
+
+func TestWatcherConcurrentReset(t *testing.T) {
+	w := Watch([]string{filepath.Join(t.TempDir(), "*")}, time.Hour)
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				w.Reset(time.Duration(i*20+j+1) * time.Millisecond)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if !wait(w, 5*time.Second) {
+		t.Error("no refresh after reset")
+	}
+	w.Close()
+	// Resetting a closed watcher must not block.
+	w.Reset(time.Second)
+}
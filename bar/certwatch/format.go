@@ -0,0 +1,44 @@
+package certwatch
+
+import (
+	"fmt"
+	"time"
+)
+
+// Duration renders a time passed or remaining of a certificate, e.g. "42s",
+// "5m", "3.2h" or "12.5d", or "expired" if there is none.
+func Duration(d time.Duration) string {
+	seconds := int64(d / time.Second)
+	if seconds <= 0 {
+		return "expired"
+	} else if seconds < 60 {
+		return fmt.Sprintf("%ds", seconds)
+	} else if seconds < 60*60 {
+		return fmt.Sprintf("%dm", seconds/60)
+	} else if seconds < 24*60*60 {
+		return fmt.Sprintf("%.1fh", float64(seconds)/60/60)
+	} else {
+		return fmt.Sprintf("%.1fd", float64(seconds)/60/60/24)
+	}
+}
+
+// RemainingFraction returns the fraction of the validity period that is
+// left, from 1 for a certificate that just became valid to 0 once expired.
+func RemainingFraction(timePassed, timeRemaining time.Duration) float64 {
+	if timeRemaining <= 0 {
+		return 0
+	}
+	return 1 - float64(timePassed)/(float64(timePassed)+float64(timeRemaining))
+}
+
+// ColorScheme returns the name of the color scheme for a certificate with
+// the given fraction of its validity left: "bad" below a quarter,
+// "degraded" below half and "good" otherwise.
+func ColorScheme(remainingFraction float64) string {
+	if remainingFraction < 0.25 {
+		return "bad"
+	} else if remainingFraction < 0.5 {
+		return "degraded"
+	}
+	return "good"
+}
@@ -0,0 +1,55 @@
+package certwatch
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestDuration(t *testing.T) {
+	for d, want := range map[time.Duration]string{
+		-time.Second:                   "expired",
+		0:                              "expired",
+		999 * time.Millisecond:         "expired",
+		42 * time.Second:               "42s",
+		5*time.Minute + 59*time.Second: "5m",
+		3*time.Hour + 12*time.Minute:   "3.2h",
+		300 * time.Hour:                "12.5d",
+	} {
+		if got := Duration(d); got != want {
+			t.Errorf("Duration(%v) = %q, want %q", d, got, want)
+		}
+	}
+}
+
+func TestRemainingFraction(t *testing.T) {
+	for _, tc := range []struct {
+		passed, remaining time.Duration
+		want              float64
+	}{
+		{0, time.Hour, 1},
+		{3 * time.Hour, time.Hour, 0.25},
+		{time.Hour, 0, 0},
+		// Certificates valid forever.
+		{time.Hour, math.MaxInt64, 1},
+	} {
+		if got := RemainingFraction(tc.passed, tc.remaining); math.Abs(got-tc.want) > 1e-6 {
+			t.Errorf("RemainingFraction(%v, %v) = %v, want %v", tc.passed, tc.remaining, got, tc.want)
+		}
+	}
+}
+
+func TestColorScheme(t *testing.T) {
+	for fraction, want := range map[float64]string{
+		0:    "bad",
+		0.24: "bad",
+		0.25: "degraded",
+		0.49: "degraded",
+		0.5:  "good",
+		1:    "good",
+	} {
+		if got := ColorScheme(fraction); got != want {
+			t.Errorf("ColorScheme(%v) = %q, want %q", fraction, got, want)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package certwatch
+
+import (
+	"github.com/multiplay/go-cticker"
+	"time"
+)
+
+// ticker is a cticker.Ticker whose interval can be changed. It must only be
+// used by a single goroutine.
+type ticker struct {
+	*cticker.Ticker
+	interval time.Duration
+}
+
+func newTicker(interval time.Duration) *ticker {
+	return &ticker{
+		Ticker:   cticker.New(interval, accuracy(interval)),
+		interval: interval,
+	}
+}
+
+// reset replaces the underlying ticker if the interval changed.
+func (t *ticker) reset(interval time.Duration) {
+	if interval == t.interval {
+		return
+	}
+	t.Ticker.Stop()
+	t.Ticker = cticker.New(interval, accuracy(interval))
+	t.interval = interval
+}
+
+// Stop stops the current underlying ticker.
+func (t *ticker) Stop() {
+	t.Ticker.Stop()
+}
+
+// accuracy returns how far a tick may deviate from the interval boundary.
+func accuracy(interval time.Duration) time.Duration {
+	if interval <= time.Second {
+		return interval / 10
+	}
+	return interval / 60
+}
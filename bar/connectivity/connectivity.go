@@ -0,0 +1,227 @@
+// Package connectivity provides an indicator for whether the network
+// actually reaches the internet, distinguishing captive portals and missing
+// DNS from full connectivity. It follows NetworkManager's connectivity check
+// if available and probes an HTTP URL otherwise.
+package connectivity
+
+import (
+	"errors"
+	"log"
+	"os/exec"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/base/value"
+	"barista.run/outputs"
+	"barista.run/timing"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// State is the connectivity of the system, with the values of
+// NMConnectivityState.
+type State uint32
+
+const (
+	// Unknown means connectivity could not be checked.
+	Unknown State = iota
+	// None means there is no network connection.
+	None
+	// Portal means a captive portal intercepts requests.
+	Portal
+	// Limited means the network does not reach the internet, e.g. because
+	// DNS does not work.
+	Limited
+	// Full means the internet is reachable.
+	Full
+)
+
+func (s State) String() string {
+	switch s {
+	case None:
+		return "none"
+	case Portal:
+		return "portal"
+	case Limited:
+		return "limited"
+	case Full:
+		return "full"
+	default:
+		return "unknown"
+	}
+}
+
+// Info is the connectivity of the system.
+type Info struct {
+	State State
+	// PortalURL is the page to log in at behind a captive portal.
+	PortalURL string
+
+	m *Module
+}
+
+// OpenPortal opens the captive portal login page in the default browser.
+func (i Info) OpenPortal() {
+	if i.PortalURL == "" {
+		return
+	}
+	if err := exec.Command("xdg-open", i.PortalURL).Start(); err != nil {
+		log.Printf("failed to open captive portal: %v", err)
+	}
+}
+
+// Check checks the connectivity again, e.g. after logging in to a portal.
+func (i Info) Check() {
+	if i.m != nil {
+		i.m.check.Set(struct{}{})
+	}
+}
+
+// Module represents a connectivity barista module.
+type Module struct {
+	probeOnly  bool
+	prober     prober
+	scheduler  *timing.Scheduler
+	outputFunc value.Value // of func(Info) bar.Output
+	check      value.Value // of struct{}
+}
+
+// New constructs a connectivity module that uses NetworkManager if it is
+// running and probes DefaultProbeURL otherwise.
+func New() *Module {
+	m := &Module{
+		prober:    newProber(DefaultProbeURL, DefaultProbeBody, 10*time.Second),
+		scheduler: timing.NewScheduler().Every(time.Minute),
+	}
+	m.Output(func(i Info) bar.Output {
+		if i.State == Full {
+			return nil
+		}
+		return outputs.Text(i.State.String()).OnClick(func(e bar.Event) {
+			if e.Button != bar.ButtonLeft {
+				return
+			}
+			if i.State == Portal {
+				i.OpenPortal()
+			} else {
+				i.Check()
+			}
+		})
+	})
+	return m
+}
+
+// Probe always checks connectivity by requesting url instead of asking
+// NetworkManager. Connectivity is full if the response is body, or if body
+// is empty, if the response is 204 No Content.
+func (m *Module) Probe(url, body string) *Module {
+	m.probeOnly = true
+	m.prober.url = url
+	m.prober.body = body
+	return m
+}
+
+// RefreshInterval configures how often connectivity is checked. With
+// NetworkManager, changes are also shown as soon as it notices them.
+func (m *Module) RefreshInterval(interval time.Duration) *Module {
+	m.scheduler.Every(interval)
+	return m
+}
+
+// Output sets the output format for the module.
+func (m *Module) Output(outputFunc func(Info) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+// Stream starts the module.
+func (m *Module) Stream(sink bar.Sink) {
+	var nm *networkManager
+	var signals chan *dbus.Signal
+	if !m.probeOnly {
+		signals = make(chan *dbus.Signal, 10)
+		var err error
+		if nm, err = connectNetworkManager(signals); err != nil {
+			log.Printf("connectivity: NetworkManager unavailable, probing %s: %v", m.prober.url, err)
+			nm, signals = nil, nil
+		} else {
+			defer nm.close()
+		}
+	}
+	probe := func() Info {
+		state, url := m.prober.probe()
+		return Info{State: state, PortalURL: url, m: m}
+	}
+	read := func(recheck bool) Info {
+		if nm == nil {
+			return probe()
+		}
+		state, err := nm.connectivity(recheck)
+		if err != nil {
+			log.Printf("failed to get connectivity from NetworkManager: %v", err)
+		}
+		if state == Unknown {
+			// The connectivity check of NetworkManager is disabled or
+			// failed.
+			return probe()
+		}
+		info := Info{State: state, m: m}
+		if state == Portal {
+			// NetworkManager does not expose the portal, so ask it
+			// directly.
+			if _, url := m.prober.probe(); url != "" {
+				info.PortalURL = url
+			} else {
+				info.PortalURL = m.prober.url
+			}
+		}
+		return info
+	}
+
+	// Probing takes up to its timeout, so later checks run in the
+	// background, one at a time. Checks requested meanwhile run once the
+	// current one finishes.
+	results := make(chan Info, 1)
+	var running, queued, queuedRecheck bool
+	check := func(recheck bool) {
+		if running {
+			queued, queuedRecheck = true, queuedRecheck || recheck
+			return
+		}
+		running = true
+		go func() { results <- read(recheck) }()
+	}
+
+	info := read(false)
+	outputFunc := m.outputFunc.Get().(func(Info) bar.Output)
+	nextOutputFunc, done := m.outputFunc.Subscribe()
+	defer done()
+	nextCheck, done := m.check.Subscribe()
+	defer done()
+	for {
+		sink.Output(outputFunc(info))
+		select {
+		case sig, ok := <-signals:
+			if !ok {
+				sink.Error(errors.New("lost connection to NetworkManager"))
+				return
+			}
+			if changed, ok := connectivityChanged(sig); ok && changed != info.State {
+				check(false)
+			}
+		case <-nextCheck:
+			check(true)
+		case <-m.scheduler.C:
+			check(false)
+		case info = <-results:
+			running = false
+			if queued {
+				queued = false
+				check(queuedRecheck)
+				queuedRecheck = false
+			}
+		case <-nextOutputFunc:
+			outputFunc = m.outputFunc.Get().(func(Info) bar.Output)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package connectivity
+
+import (
+	"github.com/godbus/dbus/v5"
+)
+
+const (
+	nmService = "org.freedesktop.NetworkManager"
+	nmPath    = "/org/freedesktop/NetworkManager"
+)
+
+// networkManager reads the result of NetworkManager's connectivity check.
+type networkManager struct {
+	conn *dbus.Conn
+	obj  dbus.BusObject
+}
+
+// connectNetworkManager connects to NetworkManager and subscribes to changes
+// of its properties.
+func connectNetworkManager(signals chan<- *dbus.Signal) (*networkManager, error) {
+	conn, err := dbus.ConnectSystemBus()
+	if err != nil {
+		return nil, err
+	}
+	nm := &networkManager{conn: conn, obj: conn.Object(nmService, nmPath)}
+	if _, err := nm.connectivity(false); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	conn.Signal(signals)
+	err = conn.AddMatchSignal(
+		dbus.WithMatchObjectPath(nmPath),
+		dbus.WithMatchInterface("org.freedesktop.DBus.Properties"),
+		dbus.WithMatchMember("PropertiesChanged"),
+		dbus.WithMatchArg(0, nmService),
+	)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return nm, nil
+}
+
+// connectivity returns the last connectivity state, or checks it again if
+// recheck is set and the user is allowed to.
+func (nm *networkManager) connectivity(recheck bool) (State, error) {
+	var state uint32
+	if recheck {
+		if err := nm.obj.Call(nmService+".CheckConnectivity", 0).Store(&state); err == nil {
+			return State(state), nil
+		}
+	}
+	v, err := nm.obj.GetProperty(nmService + ".Connectivity")
+	if err != nil {
+		return Unknown, err
+	}
+	if err := v.Store(&state); err != nil {
+		return Unknown, err
+	}
+	return State(state), nil
+}
+
+func (nm *networkManager) close() {
+	nm.conn.Close()
+}
+
+// connectivityChanged returns the new state if the signal changes it.
+func connectivityChanged(sig *dbus.Signal) (State, bool) {
+	var iface string
+	var changed map[string]dbus.Variant
+	var invalidated []string
+	if dbus.Store(sig.Body, &iface, &changed, &invalidated) != nil {
+		return Unknown, false
+	}
+	v, ok := changed["Connectivity"]
+	if !ok {
+		return Unknown, false
+	}
+	state, ok := v.Value().(uint32)
+	return State(state), ok
+}
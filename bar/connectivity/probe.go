@@ -0,0 +1,76 @@
+package connectivity
+
+import (
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// DefaultProbeURL and DefaultProbeBody are the check NetworkManager uses on
+// most distributions.
+const (
+	DefaultProbeURL  = "http://nmcheck.gnome.org/check_network_status.txt"
+	DefaultProbeBody = "NetworkManager is online"
+)
+
+// prober checks connectivity with a plain HTTP request, which captive
+// portals intercept by redirecting it or replacing the response.
+type prober struct {
+	url string
+	// body is the expected response, or empty to expect 204 No Content.
+	body   string
+	client *http.Client
+}
+
+func newProber(url, body string, timeout time.Duration) prober {
+	return prober{
+		url:  url,
+		body: body,
+		client: &http.Client{
+			Timeout: timeout,
+			// Redirects are the portal, not the answer.
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+// probe returns the connectivity state and, behind a portal, the URL to log
+// in at.
+func (p prober) probe() (State, string) {
+	resp, err := p.client.Get(p.url)
+	if err != nil {
+		var dnsErr *net.DNSError
+		var netErr net.Error
+		switch {
+		case errors.As(err, &dnsErr):
+			// Connected, but names do not resolve.
+			return Limited, ""
+		case errors.As(err, &netErr) && netErr.Timeout():
+			return Limited, ""
+		default:
+			return None, ""
+		}
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	switch {
+	case resp.StatusCode >= 300 && resp.StatusCode < 400:
+		if location, err := resp.Location(); err == nil {
+			return Portal, location.String()
+		}
+		return Portal, p.url
+	case p.body == "" && resp.StatusCode == http.StatusNoContent:
+		return Full, ""
+	case p.body != "" && resp.StatusCode == http.StatusOK && strings.TrimSpace(string(body)) == p.body:
+		return Full, ""
+	default:
+		// Anything else is a portal replacing the response. Opening the
+		// probe URL in a browser shows its login page.
+		return Portal, p.url
+	}
+}
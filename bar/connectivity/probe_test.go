@@ -0,0 +1,95 @@
+package connectivity
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"barista.run/bar"
+)
+
+func TestProbe(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/generate_204", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("/check", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, DefaultProbeBody)
+	})
+	mux.HandleFunc("/redirect", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "http://portal.example/login?from=probe", http.StatusFound)
+	})
+	mux.HandleFunc("/replaced", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "<html>Please log in</html>")
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	for _, tc := range []struct {
+		name, url, body string
+		state           State
+		portal          string
+	}{
+		{"204", srv.URL + "/generate_204", "", Full, ""},
+		{"body", srv.URL + "/check", DefaultProbeBody, Full, ""},
+		{"redirect", srv.URL + "/redirect", DefaultProbeBody, Portal, "http://portal.example/login?from=probe"},
+		{"replaced body", srv.URL + "/replaced", DefaultProbeBody, Portal, srv.URL + "/replaced"},
+		{"content instead of 204", srv.URL + "/replaced", "", Portal, srv.URL + "/replaced"},
+		{"dns failure", "http://probe.invalid/", "", Limited, ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			state, portal := newProber(tc.url, tc.body, 5*time.Second).probe()
+			if state != tc.state || portal != tc.portal {
+				t.Errorf("got %v %q, want %v %q", state, portal, tc.state, tc.portal)
+			}
+		})
+	}
+}
+
+func TestStreamDoesNotBlockOnProbe(t *testing.T) {
+	release := make(chan struct{})
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Every request but the first hangs like behind a slow network.
+		if requests.Add(1) > 1 {
+			<-release
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	infos := make(chan Info, 10)
+	outputFunc := func(tag string) func(Info) bar.Output {
+		return func(i Info) bar.Output {
+			infos <- Info{State: i.State, PortalURL: tag}
+			return nil
+		}
+	}
+	m := New().Probe(srv.URL, "").Output(outputFunc("first"))
+	go m.Stream(bar.Sink(func(bar.Output) {}))
+
+	next := func() Info {
+		t.Helper()
+		select {
+		case i := <-infos:
+			return i
+		case <-time.After(2 * time.Second):
+			t.Fatal("no output")
+			return Info{}
+		}
+	}
+	if i := next(); i.State != Full || i.PortalURL != "first" {
+		t.Fatalf("got %+v, want full connectivity", i)
+	}
+	Info{m: m}.Check()
+	m.Output(outputFunc("second"))
+	for {
+		if i := next(); i.PortalURL == "second" {
+			break
+		}
+	}
+}
@@ -0,0 +1,136 @@
+// Package cpu provides a cpu utilisation indicator based on /proc/stat.
+package cpu
+
+import (
+	"os"
+	"sort"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/base/value"
+	"barista.run/outputs"
+	"barista.run/timing"
+)
+
+// Info is the cpu utilisation since the previous update.
+type Info struct {
+	Usage
+	// Cores holds the usage of each core that was online during both
+	// samples, ordered by id.
+	Cores []CoreUsage
+}
+
+// CoreUsage is the utilisation of a single core.
+type CoreUsage struct {
+	// ID is the number of the core, N in cpuN.
+	ID int
+	Usage
+}
+
+// CoreBusy returns the busy fraction of each core.
+func (i Info) CoreBusy() []float64 {
+	busy := make([]float64, len(i.Cores))
+	for n, core := range i.Cores {
+		busy[n] = core.Busy
+	}
+	return busy
+}
+
+// Module represents a cpu barista module.
+type Module struct {
+	statFile   string
+	scheduler  *timing.Scheduler
+	outputFunc value.Value // of func(Info) bar.Output
+}
+
+// New constructs a cpu module reading /proc/stat.
+func New() *Module {
+	m := &Module{
+		statFile:  "/proc/stat",
+		scheduler: timing.NewScheduler().Every(3 * time.Second),
+	}
+	m.Output(func(i Info) bar.Output {
+		return outputs.Textf("%.0f%%", i.Busy*100)
+	})
+	return m
+}
+
+// StatFile sets the file to read instead of /proc/stat.
+func (m *Module) StatFile(statFile string) *Module {
+	m.statFile = statFile
+	return m
+}
+
+// RefreshInterval configures the polling frequency.
+func (m *Module) RefreshInterval(interval time.Duration) *Module {
+	m.scheduler.Every(interval)
+	return m
+}
+
+// Output sets the output format for the module.
+func (m *Module) Output(outputFunc func(Info) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+// firstSample is how long the first utilisation is measured, so that the
+// module does not wait for the refresh interval before showing anything.
+const firstSample = 250 * time.Millisecond
+
+// Stream starts the module.
+func (m *Module) Stream(sink bar.Sink) {
+	prev, err := m.readStat()
+	if sink.Error(err) {
+		return
+	}
+	time.Sleep(firstSample)
+	cur, err := m.readStat()
+	if sink.Error(err) {
+		return
+	}
+	info := delta(prev, cur)
+	prev = cur
+	outputFunc := m.outputFunc.Get().(func(Info) bar.Output)
+	nextOutputFunc, done := m.outputFunc.Subscribe()
+	defer done()
+	for {
+		sink.Output(outputFunc(info))
+		select {
+		case <-m.scheduler.C:
+			cur, err := m.readStat()
+			if sink.Error(err) {
+				return
+			}
+			info = delta(prev, cur)
+			prev = cur
+		case <-nextOutputFunc:
+			outputFunc = m.outputFunc.Get().(func(Info) bar.Output)
+		}
+	}
+}
+
+func (m *Module) readStat() (Stat, error) {
+	f, err := os.Open(m.statFile)
+	if err != nil {
+		return Stat{}, err
+	}
+	defer f.Close()
+	return ParseStat(f)
+}
+
+// delta computes the utilisation between two samples. Cores are matched by
+// id, those that are missing from either sample are skipped.
+func delta(prev, cur Stat) Info {
+	info := Info{Usage: usage(prev.Total, cur.Total)}
+	prevCores := make(map[int]Times, len(prev.Cores))
+	for _, core := range prev.Cores {
+		prevCores[core.ID] = core.Times
+	}
+	for _, core := range cur.Cores {
+		if times, ok := prevCores[core.ID]; ok {
+			info.Cores = append(info.Cores, CoreUsage{ID: core.ID, Usage: usage(times, core.Times)})
+		}
+	}
+	sort.Slice(info.Cores, func(i, j int) bool { return info.Cores[i].ID < info.Cores[j].ID })
+	return info
+}
@@ -0,0 +1,118 @@
+package cpu
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Times are the cumulative jiffies a cpu spent in each state, as reported by
+// /proc/stat.
+type Times struct {
+	User, Nice, System, Idle, IOWait, IRQ, SoftIRQ, Steal uint64
+}
+
+// Total returns the sum of all states. Guest time is already accounted in
+// user and nice and therefore not included.
+func (t Times) Total() uint64 {
+	return t.User + t.Nice + t.System + t.Idle + t.IOWait + t.IRQ + t.SoftIRQ + t.Steal
+}
+
+// idle returns the time spent idle, including waiting for io.
+func (t Times) idle() uint64 {
+	return t.Idle + t.IOWait
+}
+
+// Core holds the times of a single online core.
+type Core struct {
+	// ID is the number of the core, N in cpuN.
+	ID int
+	Times
+}
+
+// Stat holds the times of all cpus combined and of each online core.
+type Stat struct {
+	Total Times
+	Cores []Core
+}
+
+// ParseStat parses the cpu lines of /proc/stat.
+func ParseStat(r io.Reader) (Stat, error) {
+	var stat Stat
+	found := false
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) == 0 || !strings.HasPrefix(fields[0], "cpu") {
+			continue
+		}
+		times, err := parseTimes(fields[1:])
+		if err != nil {
+			return Stat{}, fmt.Errorf("%s: %w", fields[0], err)
+		}
+		if fields[0] == "cpu" {
+			stat.Total = times
+			found = true
+			continue
+		}
+		id, err := strconv.Atoi(strings.TrimPrefix(fields[0], "cpu"))
+		if err != nil {
+			return Stat{}, fmt.Errorf("invalid cpu %q", fields[0])
+		}
+		stat.Cores = append(stat.Cores, Core{ID: id, Times: times})
+	}
+	if err := s.Err(); err != nil {
+		return Stat{}, err
+	}
+	if !found {
+		return Stat{}, fmt.Errorf("no cpu line found")
+	}
+	return stat, nil
+}
+
+func parseTimes(fields []string) (Times, error) {
+	// Older kernels report fewer columns, missing ones stay zero.
+	values := make([]uint64, 8)
+	for i := 0; i < len(fields) && i < len(values); i++ {
+		v, err := strconv.ParseUint(fields[i], 10, 64)
+		if err != nil {
+			return Times{}, err
+		}
+		values[i] = v
+	}
+	return Times{
+		User:    values[0],
+		Nice:    values[1],
+		System:  values[2],
+		Idle:    values[3],
+		IOWait:  values[4],
+		IRQ:     values[5],
+		SoftIRQ: values[6],
+		Steal:   values[7],
+	}, nil
+}
+
+// Usage is the fraction of time a cpu spent in each state between two
+// samples.
+type Usage struct {
+	// Busy is the fraction of time not spent idle or waiting for io.
+	Busy   float64
+	IOWait float64
+	Steal  float64
+}
+
+// usage computes the usage between two samples of the same cpu.
+func usage(prev, cur Times) Usage {
+	total := float64(cur.Total()) - float64(prev.Total())
+	if total <= 0 {
+		return Usage{}
+	}
+	idle := float64(cur.idle()) - float64(prev.idle())
+	return Usage{
+		Busy:   1 - idle/total,
+		IOWait: (float64(cur.IOWait) - float64(prev.IOWait)) / total,
+		Steal:  (float64(cur.Steal) - float64(prev.Steal)) / total,
+	}
+}
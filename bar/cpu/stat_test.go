@@ -0,0 +1,82 @@
+package cpu
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func readFixture(t *testing.T, name string) Stat {
+	f, err := os.Open(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	stat, err := ParseStat(f)
+	if err != nil {
+		t.Fatalf("ParseStat(%s): %v", name, err)
+	}
+	return stat
+}
+
+func TestParseStat(t *testing.T) {
+	stat := readFixture(t, "stat")
+	want := Times{User: 10132153, Nice: 290696, System: 3084719, Idle: 46828483, IOWait: 16683, SoftIRQ: 25195}
+	if stat.Total != want {
+		t.Errorf("got total %+v, want %+v", stat.Total, want)
+	}
+	if len(stat.Cores) != 4 {
+		t.Fatalf("got %d cores, want 4", len(stat.Cores))
+	}
+	for n, core := range stat.Cores {
+		if core.ID != n {
+			t.Errorf("core %d has id %d", n, core.ID)
+		}
+	}
+	if got := stat.Cores[2].User; got != 3639312 {
+		t.Errorf("got user time %d of cpu2, want 3639312", got)
+	}
+}
+
+func TestParseStatOldKernel(t *testing.T) {
+	stat := readFixture(t, "stat-old")
+	want := Times{User: 4705, Nice: 356, System: 584, Idle: 3699}
+	if stat.Total != want || len(stat.Cores) != 1 || stat.Cores[0].Times != want {
+		t.Errorf("got %+v, want total and cpu0 %+v", stat, want)
+	}
+}
+
+func TestParseStatInvalid(t *testing.T) {
+	f, err := os.Open(filepath.Join("testdata", "stat-invalid"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := ParseStat(f); err == nil {
+		t.Error("no error for an invalid cpu line")
+	}
+}
+
+func TestDeltaOfflineCore(t *testing.T) {
+	// cpu2 went offline between the samples.
+	info := delta(readFixture(t, "stat"), readFixture(t, "stat-offline"))
+	near := func(a, b float64) bool { return math.Abs(a-b) < 1e-9 }
+	if !near(info.Busy, 0.5) || !near(info.IOWait, 3.0/14) || !near(info.Steal, 1.0/14) {
+		t.Errorf("got total usage %+v, want busy 0.5, iowait 3/14, steal 1/14", info.Usage)
+	}
+	want := []CoreUsage{
+		{ID: 0, Usage: Usage{Busy: 2.0 / 3}},
+		{ID: 1, Usage: Usage{Busy: 0.4, IOWait: 0.6, Steal: 0.2}},
+		{ID: 3, Usage: Usage{Busy: 0.5}},
+	}
+	if len(info.Cores) != len(want) {
+		t.Fatalf("got cores %+v, want %+v", info.Cores, want)
+	}
+	for n, core := range info.Cores {
+		w := want[n]
+		if core.ID != w.ID || !near(core.Busy, w.Busy) || !near(core.IOWait, w.IOWait) || !near(core.Steal, w.Steal) {
+			t.Errorf("got core %+v, want %+v", core, w)
+		}
+	}
+}
@@ -0,0 +1,292 @@
+// Package dnd provides a do-not-disturb indicator and toggle for the
+// notification daemon, which can also pause notifications automatically
+// while a fullscreen window or a screen sharing application is focused.
+package dnd
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/base/value"
+	"barista.run/outputs"
+	"barista.run/timing"
+
+	"github.com/godbus/dbus/v5"
+	"go.i3wm.org/i3/v4"
+)
+
+// Info is the state of the notification daemon.
+type Info struct {
+	// Server is the name of the notification daemon, e.g. "dunst".
+	Server string
+	// Supported reports whether the daemon can be paused, which currently
+	// requires dunst.
+	Supported bool
+	// Paused reports whether notifications are held back.
+	Paused bool
+	// Pending is the number of notifications held back while paused.
+	Pending int
+	// Auto reports whether notifications were paused automatically.
+	Auto bool
+
+	m *Module
+}
+
+// Toggle pauses or resumes notifications. This overrides automatic pausing
+// until the focused window changes whether it needs it.
+func (i Info) Toggle() {
+	if i.m != nil && i.Supported {
+		i.m.setPaused(!i.Paused, false)
+	}
+}
+
+// Module represents a do-not-disturb barista module.
+type Module struct {
+	bus        func(...dbus.ConnOption) (*dbus.Conn, error)
+	fullscreen bool
+	classes    []string
+	scheduler  *timing.Scheduler
+	outputFunc value.Value // of func(Info) bar.Output
+	refresh    value.Value // of struct{}
+
+	pausing    sync.Mutex // serializes pausing and resuming
+	mu         sync.Mutex // guards the fields below
+	server     *server
+	autoPaused bool
+}
+
+// New constructs a do-not-disturb module.
+func New() *Module {
+	m := &Module{
+		bus:       dbus.ConnectSessionBus,
+		scheduler: timing.NewScheduler().Every(5 * time.Second),
+	}
+	m.Output(func(i Info) bar.Output {
+		if !i.Supported {
+			return nil
+		}
+		text := "on"
+		if i.Paused {
+			text = "DND"
+			if i.Pending > 0 {
+				text = fmt.Sprintf("DND %d", i.Pending)
+			}
+		}
+		return outputs.Text(text).OnClick(func(e bar.Event) {
+			if e.Button == bar.ButtonLeft {
+				i.Toggle()
+			}
+		})
+	})
+	return m
+}
+
+// AutoFullscreen pauses notifications while a fullscreen window is focused.
+func (m *Module) AutoFullscreen(enabled bool) *Module {
+	m.fullscreen = enabled
+	return m
+}
+
+// AutoClasses pauses notifications while a window of one of the given X11
+// classes or instances is focused, e.g. "zoom" for screen sharing.
+func (m *Module) AutoClasses(classes ...string) *Module {
+	m.classes = classes
+	return m
+}
+
+// Bus sets how to connect to the bus the notification daemon is on,
+// dbus.ConnectSessionBus by default.
+func (m *Module) Bus(connect func(...dbus.ConnOption) (*dbus.Conn, error)) *Module {
+	m.bus = connect
+	return m
+}
+
+// RefreshInterval configures how often the number of pending notifications
+// is read, since dunst does not signal changes of it.
+func (m *Module) RefreshInterval(interval time.Duration) *Module {
+	m.scheduler.Every(interval)
+	return m
+}
+
+// Output sets the output format for the module.
+func (m *Module) Output(outputFunc func(Info) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+// Stream starts the module.
+func (m *Module) Stream(sink bar.Sink) {
+	conn, err := m.bus()
+	if sink.Error(err) {
+		return
+	}
+	defer conn.Close()
+	srv := newServer(conn)
+	signals := make(chan *dbus.Signal, 10)
+	if sink.Error(srv.watch(signals)) {
+		return
+	}
+	m.mu.Lock()
+	m.server = &srv
+	m.mu.Unlock()
+	defer func() {
+		m.mu.Lock()
+		m.server = nil
+		m.mu.Unlock()
+	}()
+
+	if m.fullscreen || len(m.classes) > 0 {
+		windows := i3.Subscribe(i3.WindowEventType, i3.WorkspaceEventType)
+		defer windows.Close()
+		go m.followFocus(windows)
+	}
+
+	info, err := m.read()
+	outputFunc := m.outputFunc.Get().(func(Info) bar.Output)
+	nextOutputFunc, done := m.outputFunc.Subscribe()
+	defer done()
+	nextRefresh, done := m.refresh.Subscribe()
+	defer done()
+	for {
+		if sink.Error(err) {
+			return
+		}
+		sink.Output(outputFunc(info))
+		select {
+		case _, ok := <-signals:
+			if !ok {
+				sink.Error(errors.New("lost connection to the bus"))
+				return
+			}
+			info, err = m.read()
+		case <-nextRefresh:
+			info, err = m.read()
+		case <-m.scheduler.C:
+			info, err = m.read()
+		case <-nextOutputFunc:
+			outputFunc = m.outputFunc.Get().(func(Info) bar.Output)
+		}
+	}
+}
+
+func (m *Module) read() (Info, error) {
+	m.mu.Lock()
+	srv, autoPaused := m.server, m.autoPaused
+	m.mu.Unlock()
+	if srv == nil {
+		return Info{}, nil
+	}
+	info, err := srv.read()
+	var dbusErr dbus.Error
+	if errors.As(err, &dbusErr) && dbusErr.Name == "org.freedesktop.DBus.Error.ServiceUnknown" {
+		// No notification daemon is running (yet).
+		return Info{}, nil
+	}
+	info.Auto = autoPaused && info.Paused
+	info.m = m
+	return info, err
+}
+
+// setPaused pauses or resumes notifications, remembering whether this was
+// done automatically.
+func (m *Module) setPaused(paused, auto bool) {
+	m.pausing.Lock()
+	defer m.pausing.Unlock()
+	m.mu.Lock()
+	srv := m.server
+	m.mu.Unlock()
+	if srv == nil {
+		return
+	}
+	if err := srv.setPaused(paused); err != nil {
+		log.Printf("failed to pause notifications: %v", err)
+		return
+	}
+	m.mu.Lock()
+	m.autoPaused = auto && paused
+	m.mu.Unlock()
+	m.refresh.Set(struct{}{})
+}
+
+// followFocus pauses notifications when the focused window starts needing
+// it and resumes them when it no longer does, unless they were paused by
+// hand in the first place.
+func (m *Module) followFocus(events *i3.EventReceiver) {
+	// The window focused when starting does not send an event.
+	active := false
+	if tree, err := i3.GetTree(); err == nil && tree.Root != nil {
+		active = m.applyFocus(tree.Root.FindFocused(func(n *i3.Node) bool { return n.Focused }), active)
+	}
+	for events.Next() {
+		if focused, ok := focusChange(events.Event()); ok {
+			active = m.applyFocus(focused, active)
+		}
+	}
+}
+
+// applyFocus pauses or resumes notifications if the focused window changes
+// whether it needs quiet, given whether the previous one did, and returns
+// whether it does.
+func (m *Module) applyFocus(focused *i3.Node, active bool) bool {
+	want := m.wantsQuiet(focused)
+	if want == active {
+		return active
+	}
+	info, err := m.read()
+	switch {
+	case err != nil || !info.Supported:
+	case want && !info.Paused:
+		m.setPaused(true, true)
+	case !want && info.Auto:
+		m.setPaused(false, false)
+	}
+	return want
+}
+
+// focusChange returns the focused window from an i3 event, and whether the
+// event may have changed it or its fullscreen mode at all.
+func focusChange(event i3.Event) (*i3.Node, bool) {
+	switch ev := event.(type) {
+	case *i3.WindowEvent:
+		// Changes of windows other than the focused one, e.g. by
+		// criteria, do not matter.
+		if ev.Change != "focus" && !ev.Container.Focused {
+			return nil, false
+		}
+		switch ev.Change {
+		case "focus", "fullscreen_mode", "move":
+			return &ev.Container, true
+		case "close":
+			// The window focused next is reported separately.
+			return nil, true
+		}
+	case *i3.WorkspaceEvent:
+		if ev.Change == "focus" {
+			return ev.Current.FindFocused(func(n *i3.Node) bool { return n.Focused }), true
+		}
+	}
+	return nil, false
+}
+
+// wantsQuiet reports whether notifications should be paused while the node
+// is focused.
+func (m *Module) wantsQuiet(n *i3.Node) bool {
+	if n == nil || n.Window == 0 {
+		return false
+	}
+	if m.fullscreen && n.FullscreenMode != i3.FullscreenNone {
+		return true
+	}
+	for _, class := range m.classes {
+		if strings.EqualFold(class, n.WindowProperties.Class) ||
+			strings.EqualFold(class, n.WindowProperties.Instance) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,206 @@
+package dnd
+
+import (
+	"bufio"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"barista.run/bar"
+
+	"github.com/godbus/dbus/v5"
+	"go.i3wm.org/i3/v4"
+)
+
+const propertiesIface = "org.freedesktop.DBus.Properties"
+
+func TestFocusChange(t *testing.T) {
+	zoom := i3.Node{Window: 1, Focused: true, WindowProperties: i3.WindowProperties{Class: "zoom"}}
+	other := i3.Node{Window: 2, WindowProperties: i3.WindowProperties{Class: "zoom"}}
+	m := New().AutoClasses("zoom")
+	for _, tc := range []struct {
+		name  string
+		event i3.Event
+		ok    bool
+		quiet bool
+	}{
+		{"focus", &i3.WindowEvent{Change: "focus", Container: zoom}, true, true},
+		{"title", &i3.WindowEvent{Change: "title", Container: zoom}, false, false},
+		{"close", &i3.WindowEvent{Change: "close", Container: zoom}, true, false},
+		{"unfocused close", &i3.WindowEvent{Change: "close", Container: other}, false, false},
+		{"unfocused fullscreen", &i3.WindowEvent{Change: "fullscreen_mode", Container: other}, false, false},
+		{"workspace", &i3.WorkspaceEvent{Change: "focus", Current: i3.Node{
+			Type:  i3.WorkspaceNode,
+			Focus: []i3.NodeID{1},
+			Nodes: []*i3.Node{{ID: 2, Window: 2}, {ID: 1, Window: 1, Focused: true, WindowProperties: zoom.WindowProperties}},
+		}}, true, true},
+		{"empty workspace", &i3.WorkspaceEvent{Change: "focus", Current: i3.Node{Type: i3.WorkspaceNode, Focused: true}}, true, false},
+		{"workspace rename", &i3.WorkspaceEvent{Change: "rename"}, false, false},
+	} {
+		focused, ok := focusChange(tc.event)
+		if ok != tc.ok || m.wantsQuiet(focused) != tc.quiet {
+			t.Errorf("%s: got change %v and quiet %v, want %v and %v", tc.name, ok, m.wantsQuiet(focused), tc.ok, tc.quiet)
+		}
+	}
+}
+
+// startBus starts a private session bus and returns its address.
+func startBus(t *testing.T) string {
+	if _, err := exec.LookPath("dbus-daemon"); err != nil {
+		t.Skip("dbus-daemon not found")
+	}
+	cmd := exec.Command("dbus-daemon", "--session", "--nofork", "--print-address",
+		"--address=unix:path="+filepath.Join(t.TempDir(), "bus"))
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	})
+	address, err := bufio.NewReader(stdout).ReadString('\n')
+	if err != nil {
+		t.Fatalf("failed to read bus address: %v", err)
+	}
+	return strings.TrimSpace(address)
+}
+
+// fakeDunst exports the notification server and dunst's control interface.
+type fakeDunst struct {
+	conn *dbus.Conn
+
+	mu      sync.Mutex
+	paused  bool
+	waiting uint32
+}
+
+// newFakeDunst exports a daemon holding back the given number of
+// notifications.
+func newFakeDunst(t *testing.T, address string, waiting uint32) *fakeDunst {
+	conn, err := dbus.Connect(address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	f := &fakeDunst{conn: conn, waiting: waiting}
+	for iface, methods := range map[string]map[string]interface{}{
+		notifications:   {"GetServerInformation": f.getServerInformation},
+		propertiesIface: {"Get": f.get, "Set": f.set},
+	} {
+		if err := conn.ExportMethodTable(methods, objectPath, iface); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := conn.RequestName(notifications, dbus.NameFlagDoNotQueue); err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func (f *fakeDunst) getServerInformation() (string, string, string, string, *dbus.Error) {
+	return "dunst", "knopwob", "1.9.0", "1.2", nil
+}
+
+func (f *fakeDunst) get(iface, name string) (dbus.Variant, *dbus.Error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch {
+	case iface == dunstIface && name == "paused":
+		return dbus.MakeVariant(f.paused), nil
+	case iface == dunstIface && name == "waitingLength":
+		return dbus.MakeVariant(f.waiting), nil
+	}
+	return dbus.Variant{}, dbus.MakeFailedError(dbus.ErrMsgInvalidArg)
+}
+
+func (f *fakeDunst) set(iface, name string, value dbus.Variant) *dbus.Error {
+	paused, ok := value.Value().(bool)
+	if iface != dunstIface || name != "paused" || !ok {
+		return dbus.MakeFailedError(dbus.ErrMsgInvalidArg)
+	}
+	f.mu.Lock()
+	f.paused = paused
+	f.mu.Unlock()
+	_ = f.conn.Emit(objectPath, propertiesIface+".PropertiesChanged",
+		dunstIface, map[string]dbus.Variant{"paused": dbus.MakeVariant(paused)}, []string{})
+	return nil
+}
+
+func (f *fakeDunst) isPaused() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.paused
+}
+
+func TestPauseFollowsFocus(t *testing.T) {
+	address := startBus(t)
+	fake := newFakeDunst(t, address, 3)
+
+	infos := make(chan Info, 100)
+	m := New().
+		Bus(func(opts ...dbus.ConnOption) (*dbus.Conn, error) {
+			return dbus.Connect(address, opts...)
+		}).
+		Output(func(i Info) bar.Output {
+			infos <- i
+			return nil
+		})
+	go m.Stream(bar.Sink(func(bar.Output) {}))
+
+	// waitFor returns the first state matching the condition.
+	waitFor := func(what string, cond func(Info) bool) Info {
+		t.Helper()
+		timeout := time.After(10 * time.Second)
+		for {
+			select {
+			case i := <-infos:
+				if cond(i) {
+					return i
+				}
+			case <-timeout:
+				t.Fatalf("timed out waiting for %s", what)
+			}
+		}
+	}
+
+	info := waitFor("dunst", func(i Info) bool { return i.Supported })
+	if info.Server != "dunst" || info.Paused || info.Pending != 3 {
+		t.Fatalf("got initial state %+v", info)
+	}
+
+	// Configured only now to not follow the focus of a real i3.
+	m.AutoClasses("zoom")
+	zoom := &i3.Node{Window: 1, Focused: true, WindowProperties: i3.WindowProperties{Class: "zoom"}}
+	terminal := &i3.Node{Window: 2, Focused: true, WindowProperties: i3.WindowProperties{Class: "kitty"}}
+
+	// A quiet window focused on start pauses, like focusing one later.
+	active := m.applyFocus(zoom, false)
+	if !active || !fake.isPaused() {
+		t.Fatalf("got active %v and paused %v when starting on a quiet window", active, fake.isPaused())
+	}
+	waitFor("automatic pause", func(i Info) bool { return i.Paused && i.Auto })
+	active = m.applyFocus(terminal, active)
+	if active || fake.isPaused() {
+		t.Fatalf("got active %v and paused %v after leaving the quiet window", active, fake.isPaused())
+	}
+	info = waitFor("resume", func(i Info) bool { return !i.Paused })
+
+	// Pausing by hand is kept when leaving a quiet window.
+	info.Toggle()
+	waitFor("manual pause", func(i Info) bool { return i.Paused && !i.Auto })
+	active = m.applyFocus(zoom, active)
+	active = m.applyFocus(terminal, active)
+	if active || !fake.isPaused() {
+		t.Fatalf("got active %v and paused %v, want the manual pause kept", active, fake.isPaused())
+	}
+	info = waitFor("manual pause", func(i Info) bool { return i.Paused })
+	info.Toggle()
+	waitFor("manual resume", func(i Info) bool { return !i.Paused })
+}
@@ -0,0 +1,69 @@
+package dnd
+
+import (
+	"github.com/godbus/dbus/v5"
+)
+
+const (
+	notifications = "org.freedesktop.Notifications"
+	objectPath    = "/org/freedesktop/Notifications"
+	// dunstIface is dunst's control interface on the notifications object.
+	dunstIface = "org.dunstproject.cmd0"
+)
+
+// server is the notification daemon on the bus.
+type server struct {
+	conn *dbus.Conn
+	obj  dbus.BusObject
+}
+
+func newServer(conn *dbus.Conn) server {
+	return server{conn: conn, obj: conn.Object(notifications, objectPath)}
+}
+
+// read returns the state of the notification daemon. Pausing is only
+// supported by dunst, for any other daemon only its name is set.
+func (s server) read() (Info, error) {
+	var name, vendor, version, spec string
+	err := s.obj.Call(notifications+".GetServerInformation", 0).Store(&name, &vendor, &version, &spec)
+	if err != nil {
+		return Info{}, err
+	}
+	info := Info{Server: name}
+	paused, err := s.obj.GetProperty(dunstIface + ".paused")
+	if err != nil {
+		// Not dunst, or a version without the control interface.
+		return info, nil
+	}
+	info.Supported = true
+	info.Paused, _ = paused.Value().(bool)
+	if waiting, err := s.obj.GetProperty(dunstIface + ".waitingLength"); err == nil {
+		if n, ok := waiting.Value().(uint32); ok {
+			info.Pending = int(n)
+		}
+	}
+	return info, nil
+}
+
+// setPaused pauses or resumes showing notifications.
+func (s server) setPaused(paused bool) error {
+	return s.obj.SetProperty(dunstIface+".paused", dbus.MakeVariant(paused))
+}
+
+// watch subscribes to changes of the daemon and its properties.
+func (s server) watch(signals chan<- *dbus.Signal) error {
+	s.conn.Signal(signals)
+	err := s.conn.AddMatchSignal(
+		dbus.WithMatchInterface("org.freedesktop.DBus"),
+		dbus.WithMatchMember("NameOwnerChanged"),
+		dbus.WithMatchArg(0, notifications),
+	)
+	if err != nil {
+		return err
+	}
+	return s.conn.AddMatchSignal(
+		dbus.WithMatchObjectPath(objectPath),
+		dbus.WithMatchInterface("org.freedesktop.DBus.Properties"),
+		dbus.WithMatchMember("PropertiesChanged"),
+	)
+}
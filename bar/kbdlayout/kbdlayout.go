@@ -0,0 +1,204 @@
+// Package kbdlayout provides a keyboard layout indicator that follows the
+// active XKB group, switches it on click and can remember the layout of each
+// i3 window.
+package kbdlayout
+
+import (
+	"errors"
+	"log"
+	"strings"
+
+	"barista.run/bar"
+	"barista.run/base/value"
+	"barista.run/outputs"
+
+	"github.com/BurntSushi/xgb"
+	"github.com/tionis/i3-tools/bar/xkb"
+	"go.i3wm.org/i3/v4"
+)
+
+// Info is the active keyboard layout.
+type Info struct {
+	// Group is the index of the active layout.
+	Group int
+	// Layouts and Variants hold the configured layouts, e.g. "us" and
+	// "dvorak", one entry per group.
+	Layouts, Variants []string
+
+	conn *xgb.Conn
+}
+
+// Layout returns the name of the active layout.
+func (i Info) Layout() string {
+	if i.Group < len(i.Layouts) {
+		return i.Layouts[i.Group]
+	}
+	return ""
+}
+
+// Variant returns the variant of the active layout, if any.
+func (i Info) Variant() string {
+	if i.Group < len(i.Variants) {
+		return i.Variants[i.Group]
+	}
+	return ""
+}
+
+// Code returns a short upper case code for the active layout, e.g. "US".
+func (i Info) Code() string {
+	return strings.ToUpper(i.Layout())
+}
+
+// Next switches to the next layout, wrapping around after the last.
+func (i Info) Next() {
+	i.SetGroup(i.Group + 1)
+}
+
+// Previous switches to the previous layout, wrapping around before the
+// first.
+func (i Info) Previous() {
+	i.SetGroup(i.Group - 1)
+}
+
+// SetGroup switches to the layout at the given index.
+func (i Info) SetGroup(group int) {
+	if i.conn == nil || len(i.Layouts) == 0 {
+		return
+	}
+	group = (group%len(i.Layouts) + len(i.Layouts)) % len(i.Layouts)
+	if err := xkb.LockGroup(i.conn, byte(group)); err != nil {
+		log.Printf("failed to switch keyboard layout: %v", err)
+	}
+}
+
+// Module represents a keyboard layout barista module.
+type Module struct {
+	perWindow  bool
+	outputFunc value.Value // of func(Info) bar.Output
+}
+
+// New constructs a keyboard layout module.
+func New() *Module {
+	m := &Module{}
+	m.Output(func(i Info) bar.Output {
+		return outputs.Text(i.Code()).OnClick(func(e bar.Event) {
+			switch e.Button {
+			case bar.ButtonLeft, bar.ScrollUp:
+				i.Next()
+			case bar.ButtonRight, bar.ScrollDown:
+				i.Previous()
+			}
+		})
+	})
+	return m
+}
+
+// PerWindow remembers the layout of each i3 window and restores it when the
+// window is focused again. Windows start with the first layout.
+func (m *Module) PerWindow(perWindow bool) *Module {
+	m.perWindow = perWindow
+	return m
+}
+
+// Output sets the output format for the module.
+func (m *Module) Output(outputFunc func(Info) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+// Stream starts the module.
+func (m *Module) Stream(sink bar.Sink) {
+	conn, sub, done, err := xkb.Subscribe()
+	if sink.Error(err) {
+		return
+	}
+	defer done()
+	if m.perWindow {
+		windows := i3.Subscribe(i3.WindowEventType)
+		defer windows.Close()
+		go followWindows(conn, windows)
+	}
+
+	info, err := read(conn)
+	outputFunc := m.outputFunc.Get().(func(Info) bar.Output)
+	nextOutputFunc, done := m.outputFunc.Subscribe()
+	defer done()
+	for {
+		if sink.Error(err) {
+			return
+		}
+		sink.Output(outputFunc(info))
+		select {
+		case <-sub.Ready():
+			events, ok := sub.Receive()
+			if !ok {
+				sink.Error(errors.New("lost connection to the X server"))
+				return
+			}
+			changed := false
+			for _, ev := range events {
+				switch ev.Type {
+				case xkb.StateNotify:
+					if int(ev.State.Group) != info.Group {
+						info.Group = int(ev.State.Group)
+						changed = true
+					}
+				default:
+					// The keymap changed, e.g. through setxkbmap.
+					info, err = read(conn)
+					changed = true
+				}
+			}
+			if !changed {
+				continue
+			}
+		case <-nextOutputFunc:
+			outputFunc = m.outputFunc.Get().(func(Info) bar.Output)
+		}
+	}
+}
+
+func read(conn *xgb.Conn) (Info, error) {
+	names, err := xkb.GetRulesNames(conn)
+	if err != nil {
+		return Info{}, err
+	}
+	state, err := xkb.GetState(conn)
+	if err != nil {
+		return Info{}, err
+	}
+	return Info{
+		Group:    int(state.Group),
+		Layouts:  names.Layouts,
+		Variants: names.Variants,
+		conn:     conn,
+	}, nil
+}
+
+// followWindows saves the layout of the window losing focus and restores
+// the layout of the focused window until the receiver is closed.
+func followWindows(conn *xgb.Conn, windows *i3.EventReceiver) {
+	groups := map[i3.NodeID]byte{}
+	var focused i3.NodeID
+	for windows.Next() {
+		ev, ok := windows.Event().(*i3.WindowEvent)
+		if !ok {
+			continue
+		}
+		switch ev.Change {
+		case "focus":
+			if state, err := xkb.GetState(conn); err == nil && focused != 0 {
+				groups[focused] = state.Group
+			}
+			focused = ev.Container.ID
+			if err := xkb.LockGroup(conn, groups[focused]); err != nil {
+				log.Printf("failed to restore keyboard layout: %v", err)
+			}
+		case "close":
+			delete(groups, ev.Container.ID)
+			if focused == ev.Container.ID {
+				focused = 0
+			}
+		}
+	}
+}
@@ -0,0 +1,185 @@
+// Package locks provides a Caps Lock and Num Lock indicator that is updated
+// from XKB state events instead of polling.
+//
+// Compose is handled by the input method of each client and is not part of
+// the keyboard state, so it cannot be shown here.
+package locks
+
+import (
+	"errors"
+	"image/color"
+	"log"
+
+	"barista.run/bar"
+	"barista.run/base/value"
+	"barista.run/colors"
+	"barista.run/outputs"
+
+	"github.com/BurntSushi/xgb"
+	"github.com/tionis/i3-tools/bar/xkb"
+)
+
+// Info is the state of the lock keys.
+type Info struct {
+	CapsLock, NumLock bool
+
+	conn    *xgb.Conn
+	numMask byte
+}
+
+// ToggleCapsLock turns Caps Lock on or off.
+func (i Info) ToggleCapsLock() {
+	i.lock(xkb.LockMask, i.CapsLock)
+}
+
+// ToggleNumLock turns Num Lock on or off.
+func (i Info) ToggleNumLock() {
+	i.lock(i.numMask, i.NumLock)
+}
+
+func (i Info) lock(mask byte, on bool) {
+	if i.conn == nil {
+		return
+	}
+	var locks byte
+	if !on {
+		locks = mask
+	}
+	if err := xkb.LockMods(i.conn, mask, locks); err != nil {
+		log.Printf("failed to toggle lock key: %v", err)
+	}
+}
+
+// Module represents a lock keys barista module.
+type Module struct {
+	onColor, offColor color.Color
+	hideOff           bool
+	urgentCaps        bool
+	outputFunc        value.Value // of func(Info) bar.Output
+}
+
+// New constructs a lock keys module. By default each lock key is shown in
+// the degraded color while on and hidden while off.
+func New() *Module {
+	m := &Module{hideOff: true}
+	m.Output(m.defaultOutput)
+	return m
+}
+
+// Colors sets the colors of lock keys that are on and off. A nil color uses
+// the default color of the bar, and a nil on color uses the degraded color.
+func (m *Module) Colors(on, off color.Color) *Module {
+	m.onColor, m.offColor = on, off
+	return m
+}
+
+// HideOff hides lock keys that are off.
+func (m *Module) HideOff(hide bool) *Module {
+	m.hideOff = hide
+	return m
+}
+
+// UrgentCapsLock marks the output as urgent while Caps Lock is on.
+func (m *Module) UrgentCapsLock(urgent bool) *Module {
+	m.urgentCaps = urgent
+	return m
+}
+
+// Output sets the output format for the module.
+func (m *Module) Output(outputFunc func(Info) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+func (m *Module) defaultOutput(i Info) bar.Output {
+	onColor := m.onColor
+	if onColor == nil {
+		onColor = colors.Scheme("degraded")
+	}
+	out := outputs.Group()
+	key := func(name string, on bool, toggle func()) {
+		if !on && m.hideOff {
+			return
+		}
+		seg := outputs.Text(name).OnClick(func(e bar.Event) {
+			if e.Button == bar.ButtonLeft {
+				toggle()
+			}
+		})
+		if on {
+			seg.Color(onColor)
+		} else if m.offColor != nil {
+			seg.Color(m.offColor)
+		}
+		if name == "CAPS" && on && m.urgentCaps {
+			seg.Urgent(true)
+		}
+		out.Append(seg)
+	}
+	key("CAPS", i.CapsLock, i.ToggleCapsLock)
+	key("NUM", i.NumLock, i.ToggleNumLock)
+	if len(out.Segments()) == 0 {
+		return nil
+	}
+	return out
+}
+
+// Stream starts the module.
+func (m *Module) Stream(sink bar.Sink) {
+	conn, sub, done, err := xkb.Subscribe()
+	if sink.Error(err) {
+		return
+	}
+	defer done()
+
+	numMask, err := xkb.NumLockMask(conn)
+	if sink.Error(err) {
+		return
+	}
+	state, err := xkb.GetState(conn)
+	info := newInfo(conn, numMask, state)
+	outputFunc := m.outputFunc.Get().(func(Info) bar.Output)
+	nextOutputFunc, done := m.outputFunc.Subscribe()
+	defer done()
+	for {
+		if sink.Error(err) {
+			return
+		}
+		sink.Output(outputFunc(info))
+		select {
+		case <-sub.Ready():
+			events, ok := sub.Receive()
+			if !ok {
+				sink.Error(errors.New("lost connection to the X server"))
+				return
+			}
+			prev := info
+			for _, ev := range events {
+				switch ev.Type {
+				case xkb.NewKeyboardNotify:
+					// The keymap changed and Num Lock may use another modifier.
+					if numMask, err = xkb.NumLockMask(conn); err == nil {
+						state, err = xkb.GetState(conn)
+						info = newInfo(conn, numMask, state)
+					}
+				case xkb.StateNotify:
+					info = newInfo(conn, numMask, ev.State)
+				}
+			}
+			if err == nil && info == prev {
+				continue
+			}
+		case <-nextOutputFunc:
+			outputFunc = m.outputFunc.Get().(func(Info) bar.Output)
+		}
+	}
+}
+
+func newInfo(conn *xgb.Conn, numMask byte, state xkb.State) Info {
+	return Info{
+		CapsLock: state.LockedMods&xkb.LockMask != 0,
+		NumLock:  state.LockedMods&numMask != 0,
+		conn:     conn,
+		numMask:  numMask,
+	}
+}
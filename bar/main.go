@@ -7,8 +7,7 @@ import (
 	"barista.run/format"
 	"barista.run/modules/battery"
 	"barista.run/modules/clock"
-	"barista.run/modules/diskspace"
-	"barista.run/modules/meminfo"
+	"barista.run/modules/media"
 	"barista.run/modules/netinfo"
 	"barista.run/modules/sysinfo"
 	"barista.run/modules/volume"
@@ -20,8 +19,27 @@ import (
 	"os/exec"
 	"runtime"
 	"strings"
+	"github.com/tionis/i3-tools/bar/backlight"
+	"github.com/tionis/i3-tools/bar/batteries"
+	"github.com/tionis/i3-tools/bar/bluetooth"
 	"github.com/tionis/i3-tools/bar/certinfo"
+	"github.com/tionis/i3-tools/bar/connectivity"
+	"github.com/tionis/i3-tools/bar/cpu"
+	"github.com/tionis/i3-tools/bar/dnd"
+	"github.com/tionis/i3-tools/bar/kbdlayout"
+	"github.com/tionis/i3-tools/bar/locks"
+	"github.com/tionis/i3-tools/bar/memory"
+	"github.com/tionis/i3-tools/bar/nowplaying"
+	"github.com/tionis/i3-tools/bar/psi"
 	"github.com/tionis/i3-tools/bar/pulse"
+	"github.com/tionis/i3-tools/bar/sparkline"
+	"github.com/tionis/i3-tools/bar/sshagent"
+	"github.com/tionis/i3-tools/bar/storage"
+	"github.com/tionis/i3-tools/bar/temperature"
+	"github.com/tionis/i3-tools/bar/throughput"
+	"github.com/tionis/i3-tools/bar/topproc"
+	"github.com/tionis/i3-tools/bar/vpn"
+	"github.com/tionis/i3-tools/bar/x509info"
 	"github.com/tionis/i3-tools/bar/yubikey"
 	"time"
 )
@@ -32,6 +50,15 @@ const (
 	ethernetSymbol = " "
 	certSymbol     = " "
 	volumeSymbol   = " "
+	keySymbol      = " "
+	sunSymbol      = " "
+	keyboardSymbol = " "
+	btSymbol       = " "
+	musicSymbol    = " "
+	bellSymbol     = " "
+	bellOffSymbol  = " "
+	vpnSymbol      = " "
+	globeSymbol    = " "
 	//warnSymbol     = " "
 	//errorSymbol    = " "
 	//infoSymbol     = " "
@@ -46,6 +73,10 @@ type Config struct {
 	Ethernet         bool
 	Wifi             bool
 	Battery          bool
+	BatteryDetails   bool
+	BatteryNotify    []int
+	BatteryActions   []batteries.Escalation
+	BatterySnooze    time.Duration
 	IPv6             bool
 	WifiIPs          bool
 	TerminalEmulator string
@@ -53,6 +84,60 @@ type Config struct {
 	ColorBad         string
 	ColorDegraded    string
 	ShowSSHCert      bool
+	SSHCertPaths     []string
+	SSHCertShowAll   bool
+	SSHCertFormat    string
+	SSHCertDetails   string
+	SSHCertRenew     string
+	X509Certs        []string
+	X509Roots        []string
+	X509Password     string
+	ShowSSHAgent     bool
+	YubikeySources   yubikey.Source
+	YubikeyNotify    yubikey.Source
+	YubikeySound     yubikey.Source
+	YubikeySoundFile string
+	CPU              bool
+	PSI              bool
+	PSIFull          float64
+	PSITrigger       bool
+	TopProcess       bool
+	TopProcessCPU    float64
+	TopProcessMem    float64
+	Temperature      bool
+	TempSensors      []string
+	TempMax          bool
+	TempDegraded     float64
+	TempBad          float64
+	Backlight        bool
+	BacklightDevice  string
+	KeyboardLayout   bool
+	LayoutPerWindow  bool
+	LockKeys         bool
+	LockOnColor      string
+	LockOffColor     string
+	LockShowOff      bool
+	LockUrgent       bool
+	Bluetooth        bool
+	BluetoothFav     string
+	Media            bool
+	MediaExclude     []string
+	MediaWidth       int
+	DND              bool
+	DNDFullscreen    bool
+	DNDClasses       []string
+	Connectivity     bool
+	ConnectivityURL  string
+	ConnectivityBody string
+	VPN              bool
+	VPNConnections   []string
+	Mounts           []string
+	MountDiscover    bool
+	MountInclude     []string
+	MountExclude     []string
+	NetspeedIfaces   []string
+	DiskIODevices    []string
+	SparklineStyle   string
 }
 
 func Status(c Config) error {
@@ -65,7 +150,53 @@ func Status(c Config) error {
 
 	// Display information about ssh certificate
 	if c.ShowSSHCert {
-		barista.Add(certinfo.New(certSymbol + "[%s]"))
+		var m *certinfo.Module
+		if len(c.SSHCertPaths) > 0 {
+			m = certinfo.ForPaths(certSymbol+c.SSHCertFormat, c.SSHCertPaths...)
+		} else {
+			m = certinfo.New(certSymbol + c.SSHCertFormat)
+		}
+		if c.SSHCertDetails == "terminal" {
+			m.DetailsInTerminal(c.TerminalEmulator)
+		}
+		if c.SSHCertRenew != "" {
+			m.Renew(c.SSHCertRenew, 0.25)
+		}
+		barista.Add(m.ShowAll(c.SSHCertShowAll))
+	}
+
+	// Display information about x509 certificates
+	if len(c.X509Certs) > 0 {
+		m := x509info.ForPaths(certSymbol+"[{{.Name}} {{.Remaining}}]", c.X509Certs...)
+		if len(c.X509Roots) > 0 {
+			m.Roots(c.X509Roots...)
+		}
+		barista.Add(m.Password(c.X509Password))
+	}
+
+	// Display identities loaded into the ssh agent
+	if c.ShowSSHAgent {
+		barista.Add(sshagent.New().Output(func(i sshagent.Info) bar.Output {
+			var out *bar.Segment
+			switch {
+			case i.Locked:
+				out = outputs.Text(keySymbol + "[locked]").Color(colors.Scheme("bad"))
+			case i.Empty():
+				out = outputs.Text(keySymbol + "[no keys]").Color(colors.Scheme("degraded"))
+			case len(i.Certs) == 0:
+				out = outputs.Textf(keySymbol+"[%d]", i.Keys)
+			default:
+				out = outputs.Textf(keySymbol+"[%d %s]", i.Identities(), format.Duration(i.Certs[0].Remaining()))
+				if i.Certs[0].RemainingFraction() < 0.25 {
+					out.Color(colors.Scheme("bad"))
+				}
+			}
+			return out.OnClick(func(e bar.Event) {
+				if e.Button == bar.ButtonLeft {
+					_ = exec.Command(c.TerminalEmulator, "-e", "ssh-add").Run()
+				}
+			})
+		}))
 	}
 
 	// Display system load
@@ -78,24 +209,105 @@ func Status(c Config) error {
 		return out
 	}))
 
-	// storage
-	barista.Add(diskspace.New("/").Output(func(i diskspace.Info) bar.Output {
-		out := outputs.Pango(storageSymbol + format.IBytesize(i.Available))
-		switch {
-		case i.AvailFrac() < 0.025:
-			out.Color(colors.Scheme("bad"))
-		case i.Available < 3*unit.Gigabyte:
-			out.Color(colors.Scheme("bad"))
-		case i.AvailFrac() < 0.1:
-			out.Color(colors.Scheme("degraded"))
+	// Display pressure stall information
+	if c.PSI {
+		m := psi.New().FullThreshold(c.PSIFull)
+		if c.PSITrigger {
+			m.Trigger(200*time.Millisecond, 2*time.Second)
 		}
-		out.OnClick(func(e bar.Event) {
-			if e.Button == bar.ButtonLeft {
-				_ = exec.Command(c.TerminalEmulator, "-e", "gdu").Run()
+		barista.Add(m)
+	}
+
+	// Display the process hogging cpu or memory
+	if c.TopProcess {
+		barista.Add(topproc.New().Thresholds(c.TopProcessCPU, c.TopProcessMem))
+	}
+
+	// cpu usage
+	if c.CPU {
+		barista.Add(cpu.New().Output(func(i cpu.Info) bar.Output {
+			text := fmt.Sprintf("%3.0f%% %s", i.Busy*100, sparkline.Blocks(i.CoreBusy(), 1))
+			if i.IOWait >= 0.05 {
+				text += fmt.Sprintf(" wa:%.0f%%", i.IOWait*100)
 			}
-		})
-		return out
-	}))
+			if i.Steal >= 0.05 {
+				text += fmt.Sprintf(" st:%.0f%%", i.Steal*100)
+			}
+			out := outputs.Text(text)
+			switch {
+			case i.Busy > 0.9:
+				out.Color(colors.Scheme("bad"))
+			case i.Busy > 0.7 || i.IOWait > 0.2 || i.Steal > 0.2:
+				out.Color(colors.Scheme("degraded"))
+			}
+			return out.OnClick(func(e bar.Event) {
+				if e.Button == bar.ButtonLeft {
+					_ = exec.Command(c.TerminalEmulator, "-e", "htop").Run()
+				}
+			})
+		}))
+	}
+
+	// cpu temperature
+	if c.Temperature {
+		barista.Add(temperature.New(c.TempSensors...).
+			Max(c.TempMax).
+			Thresholds(c.TempDegraded, c.TempBad))
+	}
+
+	// storage
+	// Filters without mountpoints apply to the discovered mounts.
+	mounts := c.Mounts
+	if len(mounts) == 0 && !c.MountDiscover && len(c.MountInclude) == 0 && len(c.MountExclude) == 0 {
+		mounts = []string{"/"}
+	}
+	barista.Add(storage.New(mounts...).
+		Include(c.MountInclude...).
+		Exclude(c.MountExclude...).
+		Output(func(i storage.Info) bar.Output {
+			text := format.IBytesize(i.Available)
+			if len(i.Mounts) > 1 {
+				text = i.Point + " " + text
+			}
+			out := outputs.Text(storageSymbol + text)
+			switch {
+			case i.AvailFrac() < 0.025:
+				out.Color(colors.Scheme("bad"))
+			case i.Available < 3*unit.Gigabyte:
+				out.Color(colors.Scheme("bad"))
+			case i.AvailFrac() < 0.1:
+				out.Color(colors.Scheme("degraded"))
+			}
+			out.OnClick(func(e bar.Event) {
+				if e.Button == bar.ButtonLeft {
+					_ = exec.Command(c.TerminalEmulator, "-e", "gdu", i.Point).Run()
+				}
+			})
+			return out
+		}))
+
+	// disk io
+	style := sparkline.ParseStyle(c.SparklineStyle)
+	for _, device := range c.DiskIODevices {
+		barista.Add(diskIOSegment(device, style))
+	}
+
+	// now playing
+	if c.Media {
+		barista.Add(nowplaying.New(c.MediaExclude...).Output(func(i media.Info) bar.Output {
+			if !i.Playing() {
+				return nil
+			}
+			track := i.Title
+			if i.Artist != "" {
+				track = i.Artist + " - " + i.Title
+			}
+			return outputs.Repeat(func(now time.Time) bar.Output {
+				return outputs.Textf("%s[%s]", musicSymbol, nowplaying.Scroll(track, c.MediaWidth, now)).
+					OnClick(nowplaying.Controls(i))
+			}).Every(nowplaying.ScrollInterval)
+		}))
+	}
 
 	// volume
 	barista.Add(volume.New(pulse.DefaultSink()).Output(func(v volume.Volume) bar.Output {
@@ -111,19 +323,122 @@ func Status(c Config) error {
 		return outputs.Textf("%s[%02d%%]", volumeSymbol, v.Pct())
 	}))*/
 
+	// bluetooth
+	if c.Bluetooth {
+		barista.Add(bluetooth.New().Favorite(c.BluetoothFav).Output(func(i bluetooth.Info) bar.Output {
+			if !i.Available() {
+				return nil
+			}
+			var out *bar.Segment
+			switch connected := i.Connected(); {
+			case !i.Powered():
+				out = outputs.Text(btSymbol + "[off]").Color(colors.Scheme("degraded"))
+			case len(connected) == 0:
+				out = outputs.Text(btSymbol + "[on]")
+			default:
+				devices := make([]string, len(connected))
+				low := false
+				for n, d := range connected {
+					devices[n] = d.Alias
+					if d.Battery >= 0 {
+						devices[n] += fmt.Sprintf(" %d%%", d.Battery)
+						low = low || d.Battery < 15
+					}
+				}
+				out = outputs.Textf("%s[%s]", btSymbol, strings.Join(devices, ", "))
+				if low {
+					out.Color(colors.Scheme("bad"))
+				}
+			}
+			return out.OnClick(func(e bar.Event) {
+				switch e.Button {
+				case bar.ButtonLeft:
+					i.TogglePower()
+				case bar.ButtonRight:
+					i.ToggleFavorite()
+				case bar.ButtonMiddle:
+					_ = exec.Command(c.TerminalEmulator, "-e", "bluetoothctl").Run()
+				}
+			})
+		}))
+	}
+
+	// screen brightness
+	if c.Backlight {
+		barista.Add(backlight.Named(c.BacklightDevice).Output(func(i backlight.Info) bar.Output {
+			out := outputs.Textf(sunSymbol+"[%d%%]", i.Pct())
+			if i.Toggled {
+				out.Color(colors.Scheme("degraded"))
+			}
+			return out.OnClick(func(e bar.Event) {
+				switch e.Button {
+				case bar.ScrollUp:
+					i.Up()
+				case bar.ScrollDown:
+					i.Down()
+				case bar.ButtonLeft:
+					i.Toggle()
+				}
+			})
+		}))
+	}
+
+	// keyboard layout
+	if c.KeyboardLayout {
+		barista.Add(kbdlayout.New().PerWindow(c.LayoutPerWindow).Output(func(i kbdlayout.Info) bar.Output {
+			if len(i.Layouts) < 2 {
+				return nil
+			}
+			return outputs.Textf("%s[%s]", keyboardSymbol, i.Code()).OnClick(func(e bar.Event) {
+				switch e.Button {
+				case bar.ButtonLeft, bar.ScrollUp:
+					i.Next()
+				case bar.ButtonRight, bar.ScrollDown:
+					i.Previous()
+				}
+			})
+		}))
+	}
+
+	// caps and num lock
+	if c.LockKeys {
+		barista.Add(locks.New().
+			Colors(colors.Hex(c.LockOnColor), colors.Hex(c.LockOffColor)).
+			HideOff(!c.LockShowOff).
+			UrgentCapsLock(c.LockUrgent))
+	}
+
+	// notifications
+	if c.DND {
+		barista.Add(dnd.New().AutoFullscreen(c.DNDFullscreen).AutoClasses(c.DNDClasses...).Output(func(i dnd.Info) bar.Output {
+			if !i.Supported {
+				return nil
+			}
+			out := outputs.Text(bellSymbol)
+			if i.Paused {
+				out = outputs.Text(bellOffSymbol).Color(colors.Scheme("degraded"))
+				if i.Pending > 0 {
+					out = outputs.Textf("%s[%d]", bellOffSymbol, i.Pending).Color(colors.Scheme("degraded"))
+				}
+			}
+			return out.OnClick(func(e bar.Event) {
+				if e.Button == bar.ButtonLeft {
+					i.Toggle()
+				}
+			})
+		}))
+	}
+
 	// Display yubikey touch prompt
-	barista.Add(yubikey.New().Output(func(gpg, u2f bool) bar.Output {
-		var reason []string
-		if gpg {
-			reason = append(reason, "GPG")
-		}
-		if u2f {
-			reason = append(reason, "U2F")
-		}
-		if len(reason) == 0 {
+	yk := yubikey.New().
+		Sources(c.YubikeySources).
+		Notify(c.YubikeyNotify).
+		Sound(c.YubikeySound, c.YubikeySoundFile)
+	barista.Add(yk.Output(func(t yubikey.Touch) bar.Output {
+		if !t.Pending() {
 			return nil
 		}
-		out := outputs.Textf("[YK: %s]", strings.Join(reason, ","))
+		out := outputs.Textf("[YK: %s]", strings.Join(t.Reasons(), ","))
 		out.Urgent(true)
 		return out
 	}))
@@ -165,6 +480,7 @@ func Status(c Config) error {
 			return nil
 		}
 	}))
+	addNetspeed(c, style, true)
 
 	if c.Ethernet {
 		barista.Add(netinfo.Prefix("e").Output(func(s netinfo.State) bar.Output {
@@ -184,6 +500,70 @@ func Status(c Config) error {
 			}
 		}))
 	}
+	addNetspeed(c, style, false)
+
+	// connectivity
+	if c.Connectivity {
+		m := connectivity.New()
+		if c.ConnectivityURL != "" {
+			m.Probe(c.ConnectivityURL, c.ConnectivityBody)
+		}
+		barista.Add(m.Output(func(i connectivity.Info) bar.Output {
+			var out *bar.Segment
+			switch i.State {
+			case connectivity.Full:
+				return nil
+			case connectivity.Portal:
+				out = outputs.Text(globeSymbol + "[portal]").Color(colors.Scheme("degraded"))
+			case connectivity.Limited:
+				out = outputs.Text(globeSymbol + "[limited]").Color(colors.Scheme("degraded"))
+			case connectivity.None:
+				out = outputs.Text(globeSymbol + "[offline]").Color(colors.Scheme("bad"))
+			default:
+				out = outputs.Text(globeSymbol + "[unknown]")
+			}
+			return out.OnClick(func(e bar.Event) {
+				if e.Button != bar.ButtonLeft {
+					return
+				}
+				if i.State == connectivity.Portal {
+					i.OpenPortal()
+				} else {
+					i.Check()
+				}
+			})
+		}))
+	}
+
+	// vpn
+	if c.VPN {
+		barista.Add(vpn.New().Connections(c.VPNConnections...).Output(func(i vpn.Info) bar.Output {
+			out := outputs.Group()
+			for _, iface := range i.WireGuard {
+				out.Append(wireguardSegment(iface.Name, iface))
+			}
+			for _, conn := range i.Connections {
+				conn := conn
+				var seg *bar.Segment
+				switch {
+				case conn.WireGuard != nil:
+					seg = wireguardSegment(conn.ID, *conn.WireGuard)
+				case conn.Active():
+					seg = outputs.Textf("%s[%s]", vpnSymbol, conn.ID).Color(colors.Scheme("good"))
+				case conn.State == vpn.Activating:
+					seg = outputs.Textf("%s[%s...]", vpnSymbol, conn.ID).Color(colors.Scheme("degraded"))
+				default:
+					seg = outputs.Textf("%s[%s off]", vpnSymbol, conn.ID)
+				}
+				out.Append(seg.OnClick(func(e bar.Event) {
+					if e.Button == bar.ButtonLeft {
+						i.Toggle(conn)
+					}
+				}))
+			}
+			return out
+		}))
+	}
 
 	// battery
 	statusName := map[battery.Status]string{
@@ -192,48 +572,89 @@ func Status(c Config) error {
 		battery.NotCharging: " ",
 		battery.Unknown:     "",
 	}
-	barista.Add(battery.All().Output(func(b battery.Info) bar.Output {
-		if b.Status == battery.Disconnected {
-			return outputs.Text("NO BATTERY").Color(colors.Scheme("bad"))
+	if c.Battery {
+		m := batteries.New().NotifyAt(c.BatteryNotify...).Escalate(c.BatteryActions...).Backlight(c.BacklightDevice)
+		barista.Add(m.Output(func(b batteries.Info) bar.Output {
+			if b.Status == battery.Disconnected {
+				return outputs.Text("NO BATTERY").Color(colors.Scheme("bad"))
+			}
+			var text string
+			if b.Status == battery.Full {
+				text = "FULL"
+			} else {
+				text = fmt.Sprintf("%s %d%%", statusName[b.Status], b.RemainingPct())
+				if remainingTime := b.RemainingTime(); remainingTime != 0 {
+					text += " " + remainingTime.String()
+				}
+			}
+			if c.BatteryDetails {
+				text += batteryDetails(b)
+			}
+			if b.Snoozed() {
+				text += " [snoozed]"
+			}
+			out := outputs.Text(text)
+			out.OnClick(func(e bar.Event) {
+				if e.Button == bar.ButtonLeft && len(c.BatteryActions) > 0 {
+					if b.Snoozed() {
+						b.Snooze(0)
+					} else {
+						b.Snooze(c.BatterySnooze)
+					}
+				}
+			})
+			remainingTime := b.RemainingTime()
+			remainingPct := b.RemainingPct()
+			if b.Discharging() {
+				if remainingPct < 10 || (remainingTime != 0 && remainingTime < 10*time.Minute) {
+					out.Color(colors.Scheme("bad")).Urgent(true)
+				} else if remainingPct < 20 || (remainingTime != 0 && remainingTime < 30*time.Minute) {
+					out.Color(colors.Scheme("bad"))
+				}
+			}
+			return out
+		}))
+	}
+
+	// ram
+	barista.Add(memory.New().Output(func(i memory.Info) bar.Output {
+		text := fmt.Sprintf(`%s/%s`,
+			format.IBytesize(i.Info["MemTotal"]-i.Available()),
+			format.IBytesize(i.Available()))
+		if swap := i.SwapUsed(); swap > 0 {
+			text += " swap:" + format.IBytesize(swap)
 		}
-		if b.Status == battery.Full {
-			return outputs.Text("FULL")
+		if ratio := i.Zram.Ratio(); ratio > 0 {
+			text += fmt.Sprintf(" zram:%.1fx", ratio)
 		}
-		remainingTime := b.RemainingTime()
-		remainingPct := b.RemainingPct()
-		var out *bar.Segment
-		if remainingTime == 0 {
-			out = outputs.Textf("%s %d%%", statusName[b.Status], b.RemainingPct())
-		} else {
-			out = outputs.Textf("%s %d%% %s",
-				statusName[b.Status],
-				b.RemainingPct(),
-				b.RemainingTime())
-		}
-		if b.Discharging() {
-			if remainingPct < 10 || (remainingTime != 0 && remainingTime < 10*time.Minute) {
-				out.Color(colors.Scheme("bad")).Urgent(true)
-			} else if remainingPct < 20 || (remainingTime != 0 && remainingTime < 30*time.Minute) {
+		if ratio := i.ZswapRatio(); ratio > 0 {
+			text += fmt.Sprintf(" zswap:%.1fx", ratio)
+		}
+		// Swapping heavily is fine as long as tasks do not stall on it, so
+		// colour by pressure and only fall back to free memory without psi.
+		if i.Pressure == nil {
+			if i.Available() < 0.7*unit.Gigabyte {
+				return outputs.Textf(`MEMORY < %s`,
+					format.IBytesize(i.Available())).
+					Color(colors.Scheme("bad"))
+			}
+			out := outputs.Text(text)
+			switch {
+			case i.AvailFrac() < 0.05:
 				out.Color(colors.Scheme("bad"))
+			case i.AvailFrac() < 0.1:
+				out.Color(colors.Scheme("degraded"))
 			}
+			return out
 		}
-		return out
-	}))
-
-	// ram
-	barista.Add(meminfo.New().Output(func(i meminfo.Info) bar.Output {
-		if i.Available() < 0.7*unit.Gigabyte {
-			return outputs.Textf(`MEMORY < %s`,
-				format.IBytesize(i.Available())).
-				Color(colors.Scheme("bad"))
-		}
-		out := outputs.Textf(`%s/%s`,
-			format.IBytesize(i["MemTotal"]-i.Available()),
-			format.IBytesize(i.Available()))
+		if i.Pressure.Some.Avg10 >= 1 {
+			text += fmt.Sprintf(" psi:%.0f%%", i.Pressure.Some.Avg10)
+		}
+		out := outputs.Text(text)
 		switch {
-		case i.AvailFrac() < 0.05:
+		case i.Pressure.Full.Avg10 >= 10 || i.Pressure.Some.Avg10 >= 40:
 			out.Color(colors.Scheme("bad"))
-		case i.AvailFrac() < 0.1:
+		case i.Pressure.Some.Avg10 >= 10:
 			out.Color(colors.Scheme("degraded"))
 		}
 		return out
@@ -248,3 +669,77 @@ func Status(c Config) error {
 	// barista.SuppressSignals(true)
 	return barista.Run()
 }
+
+// addNetspeed adds a throughput segment for each configured wireless
+// interface, or for each other interface. This places the throughput of
+// wireless interfaces next to the wlan segment and all others next to the
+// ethernet segment.
+func addNetspeed(c Config, style sparkline.Style, wireless bool) {
+	for _, iface := range c.NetspeedIfaces {
+		if throughput.Wireless("/sys", iface) != wireless {
+			continue
+		}
+		barista.Add(throughput.Net(iface).Output(func(i throughput.Info) bar.Output {
+			if !i.Up {
+				return nil
+			}
+			return outputs.Pango(i.History.Render(style), " ↓", sparkline.Rate(i.In), " ↑", sparkline.Rate(i.Out))
+		}))
+	}
+}
+
+// diskIOSegment shows the read and write throughput of a disk.
+func diskIOSegment(device string, style sparkline.Style) bar.Module {
+	return throughput.Disk(device).Output(func(i throughput.Info) bar.Output {
+		if !i.Up {
+			return nil
+		}
+		return outputs.Pango(device, " ", i.History.Render(style), " R", sparkline.Rate(i.In), " W", sparkline.Rate(i.Out))
+	})
+}
+
+// batteryDetails formats the power draw, and the charge, health, cycle count
+// and charge thresholds of each pack.
+func batteryDetails(b batteries.Info) string {
+	var text string
+	if b.Power > 0 {
+		text += fmt.Sprintf(" %.1fW", b.SignedPower())
+	}
+	for _, pack := range b.Packs {
+		text += fmt.Sprintf(" [%s %d%%", pack.Name, pack.RemainingPct())
+		if health := pack.Health(); health > 0 {
+			text += fmt.Sprintf(" h:%.0f%%", health*100)
+		}
+		if pack.CycleCount > 0 {
+			text += fmt.Sprintf(" c:%d", pack.CycleCount)
+		}
+		if pack.EndThreshold > 0 {
+			text += fmt.Sprintf(" %d-%d%%", pack.StartThreshold, pack.EndThreshold)
+		}
+		text += "]"
+	}
+	return text
+}
+
+// wireguardSegment shows a WireGuard interface with the age of its last
+// handshake, which turns degraded once the peers stop answering.
+func wireguardSegment(name string, iface vpn.Interface) *bar.Segment {
+	if !iface.Up {
+		return outputs.Textf("%s[%s down]", vpnSymbol, name).Color(colors.Scheme("bad"))
+	}
+	if !iface.PeersKnown {
+		return outputs.Textf("%s[%s]", vpnSymbol, name).Color(colors.Scheme("good"))
+	}
+	last := iface.LastHandshake()
+	if last.IsZero() {
+		return outputs.Textf("%s[%s no handshake]", vpnSymbol, name).Color(colors.Scheme("degraded"))
+	}
+	received, sent := iface.Transfer()
+	out := outputs.Textf("%s[%s %s ↓%s ↑%s]", vpnSymbol, name,
+		format.Duration(time.Since(last)), format.IBytesize(received), format.IBytesize(sent))
+	// WireGuard renews the handshake every two minutes while traffic flows.
+	if time.Since(last) > 3*time.Minute {
+		return out.Color(colors.Scheme("degraded"))
+	}
+	return out.Color(colors.Scheme("good"))
+}
@@ -0,0 +1,116 @@
+// Package memory provides a memory indicator that extends meminfo with swap,
+// zram and zswap usage and the memory pressure stall information.
+package memory
+
+import (
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/format"
+	"barista.run/modules/meminfo"
+	"barista.run/outputs"
+
+	"github.com/martinlindhe/unit"
+	"github.com/tionis/i3-tools/bar/psi"
+)
+
+// Info is the memory usage.
+type Info struct {
+	meminfo.Info
+	// Zram is the usage of all zram devices.
+	Zram Zram
+	// Pressure is the memory pressure, or nil if the kernel does not
+	// support pressure stall information.
+	Pressure *psi.Pressure
+}
+
+// SwapUsed returns the used swap space, including zram swap devices.
+func (i Info) SwapUsed() unit.Datasize {
+	return i.Info["SwapTotal"] - i.Info["SwapFree"]
+}
+
+// ZswapRatio returns the compression ratio of the zswap pool, or 0 if zswap
+// is disabled or empty.
+func (i Info) ZswapRatio() float64 {
+	if i.Info["Zswap"] == 0 {
+		return 0
+	}
+	return float64(i.Info["Zswapped"] / i.Info["Zswap"])
+}
+
+// Module represents a memory barista module. It extends the meminfo module,
+// which reads /proc/meminfo once for all modules using it.
+type Module struct {
+	procRoot  string
+	sysfsRoot string
+	meminfo   *meminfo.Module
+}
+
+// New constructs a memory module.
+func New() *Module {
+	m := &Module{
+		procRoot:  "/proc",
+		sysfsRoot: "/sys",
+		meminfo:   meminfo.New(),
+	}
+	m.Output(func(i Info) bar.Output {
+		return outputs.Textf("Mem: %s", format.IBytesize(i.Available()))
+	})
+	return m
+}
+
+// Roots sets the directories the memory pressure and the zram devices are
+// read from, "/proc" and "/sys" by default.
+func (m *Module) Roots(procRoot, sysfsRoot string) *Module {
+	m.procRoot = procRoot
+	m.sysfsRoot = sysfsRoot
+	return m
+}
+
+// RefreshInterval configures the polling frequency. It applies to all
+// meminfo modules, since they share their updates.
+func (m *Module) RefreshInterval(interval time.Duration) *Module {
+	meminfo.RefreshInterval(interval)
+	return m
+}
+
+// Output sets the output format for the module.
+func (m *Module) Output(outputFunc func(Info) bar.Output) *Module {
+	m.meminfo.Output(func(i meminfo.Info) bar.Output {
+		info, err := m.read(i)
+		if err != nil {
+			return outputs.Error(err)
+		}
+		return outputFunc(info)
+	})
+	return m
+}
+
+// Stream starts the module.
+func (m *Module) Stream(sink bar.Sink) {
+	m.meminfo.Stream(sink)
+}
+
+// read adds the zram usage and the memory pressure to the meminfo.
+func (m *Module) read(mem meminfo.Info) (Info, error) {
+	info := Info{Info: mem}
+	var err error
+	info.Zram, err = readZram(m.sysfsRoot)
+	if err != nil {
+		return Info{}, err
+	}
+	pressure, err := psi.ReadFile(filepath.Join(m.procRoot, "pressure", "memory"))
+	switch {
+	case err == nil:
+		info.Pressure = &pressure
+	case errors.Is(err, fs.ErrNotExist), errors.Is(err, syscall.EOPNOTSUPP):
+		// Kernel without CONFIG_PSI, or booted with psi=0.
+	default:
+		return Info{}, err
+	}
+	return info, nil
+}
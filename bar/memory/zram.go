@@ -0,0 +1,63 @@
+package memory
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/martinlindhe/unit"
+)
+
+// Zram is the combined usage of all zram devices.
+type Zram struct {
+	// OrigData is the uncompressed size of the stored data.
+	OrigData unit.Datasize
+	// ComprData is the compressed size of the stored data.
+	ComprData unit.Datasize
+	// MemUsed is the memory used including allocator overhead.
+	MemUsed unit.Datasize
+}
+
+// Ratio returns the compression ratio including allocator overhead, or 0 if
+// no data is stored.
+func (z Zram) Ratio() float64 {
+	if z.MemUsed == 0 {
+		return 0
+	}
+	return float64(z.OrigData / z.MemUsed)
+}
+
+// readZram sums the mm_stat of all zram devices below the given sysfs root.
+// Systems without zram have no devices and report no usage.
+func readZram(sysfsRoot string) (Zram, error) {
+	var z Zram
+	stats, err := filepath.Glob(filepath.Join(sysfsRoot, "block", "zram*", "mm_stat"))
+	if err != nil {
+		return Zram{}, err
+	}
+	for _, stat := range stats {
+		data, err := os.ReadFile(stat)
+		if err != nil {
+			return Zram{}, err
+		}
+		// orig_data_size compr_data_size mem_used_total mem_limit ...
+		fields := strings.Fields(string(data))
+		if len(fields) < 3 {
+			return Zram{}, fmt.Errorf("%s: malformed mm_stat", stat)
+		}
+		var sizes [3]unit.Datasize
+		for i := range sizes {
+			bytes, err := strconv.ParseUint(fields[i], 10, 64)
+			if err != nil {
+				return Zram{}, fmt.Errorf("%s: %w", stat, err)
+			}
+			sizes[i] = unit.Datasize(bytes) * unit.Byte
+		}
+		z.OrigData += sizes[0]
+		z.ComprData += sizes[1]
+		z.MemUsed += sizes[2]
+	}
+	return z, nil
+}
@@ -0,0 +1,167 @@
+// Package nowplaying provides a media module that follows the most recently
+// active MPRIS player, instead of the one named player or the most recently
+// started one that barista's media module supports.
+package nowplaying
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/modules/media"
+	"barista.run/outputs"
+
+	"github.com/godbus/dbus/v5"
+	"golang.org/x/time/rate"
+)
+
+// ScrollInterval is how often scrolling text advances by one character.
+const ScrollInterval = 500 * time.Millisecond
+
+// Scroll returns a window of width characters into text that advances with
+// time, or text itself if it fits.
+func Scroll(text string, width int, now time.Time) string {
+	runes := []rune(text)
+	if width <= 0 || len(runes) <= width {
+		return text
+	}
+	runes = append(runes, []rune(" · ")...)
+	offset := int(now.UnixNano()/int64(ScrollInterval)) % len(runes)
+	window := make([]rune, width)
+	for n := range window {
+		window[n] = runes[(offset+n)%len(runes)]
+	}
+	return string(window)
+}
+
+// seekLimiter throttles seeking while scrolling, which some players cannot
+// keep up with.
+var seekLimiter = rate.NewLimiter(rate.Every(50*time.Millisecond), 1)
+
+// Controls returns a click handler that plays or pauses on left click,
+// skips to the next track on right click, to the previous track on middle
+// click and seeks on scroll.
+func Controls(i media.Info) func(bar.Event) {
+	return func(e bar.Event) {
+		switch e.Button {
+		case bar.ButtonLeft:
+			i.PlayPause()
+		case bar.ButtonRight, bar.ButtonForward:
+			i.Next()
+		case bar.ButtonMiddle, bar.ButtonBack:
+			i.Previous()
+		case bar.ScrollDown, bar.ScrollRight:
+			if seekLimiter.Allow() {
+				i.Seek(5 * time.Second)
+			}
+		case bar.ScrollUp, bar.ScrollLeft:
+			if seekLimiter.Allow() {
+				i.Seek(-5 * time.Second)
+			}
+		}
+	}
+}
+
+// Module represents a now playing barista module.
+type Module struct {
+	media    *media.Module
+	excluded map[string]bool
+	player   string
+}
+
+// New constructs a now playing module that ignores the given players, e.g.
+// "chromium" for browser tabs.
+func New(excluding ...string) *Module {
+	m := &Module{media: media.New(""), excluded: map[string]bool{}}
+	for _, name := range excluding {
+		m.excluded[busPrefix+name] = true
+	}
+	m.Output(func(i media.Info) bar.Output {
+		if !i.Playing() {
+			return nil
+		}
+		text := i.Title
+		if i.Artist != "" {
+			text = i.Artist + " - " + i.Title
+		}
+		return outputs.Repeat(func(now time.Time) bar.Output {
+			return outputs.Text(Scroll(text, 30, now)).OnClick(Controls(i))
+		}).Every(ScrollInterval)
+	})
+	return m
+}
+
+// Output sets the output format for the module.
+func (m *Module) Output(outputFunc func(media.Info) bar.Output) *Module {
+	m.media.Output(outputFunc)
+	return m
+}
+
+// Stream starts the module.
+func (m *Module) Stream(sink bar.Sink) {
+	conn, err := dbus.ConnectSessionBus()
+	if sink.Error(err) {
+		return
+	}
+	defer conn.Close()
+	signals := make(chan *dbus.Signal, 10)
+	if sink.Error(watch(conn, signals)) {
+		return
+	}
+	p, err := listPlayers(conn)
+	if sink.Error(err) {
+		return
+	}
+	m.follow(p)
+
+	// Players are followed until the media module stops, e.g. after an
+	// error, and the connection is only closed once they no longer are.
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case sig, ok := <-signals:
+				if !ok {
+					return
+				}
+				p.handle(sig)
+				m.follow(p)
+			case <-stop:
+				return
+			}
+		}
+	}()
+	m.media.Stream(sink)
+	close(stop)
+	wg.Wait()
+}
+
+// follow switches the media module to the most recently active player that
+// is not excluded.
+func (m *Module) follow(p *players) {
+	for _, name := range p.recent {
+		if m.isExcluded(name) {
+			continue
+		}
+		if name != m.player {
+			m.player = name
+			m.media.Player(strings.TrimPrefix(name, busPrefix))
+		}
+		return
+	}
+}
+
+// isExcluded reports whether a player is excluded, including all instances
+// of an excluded player, e.g. org.mpris.MediaPlayer2.firefox.instance123.
+func (m *Module) isExcluded(name string) bool {
+	for excluded := range m.excluded {
+		if name == excluded || strings.HasPrefix(name, excluded+".") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,131 @@
+package nowplaying
+
+import (
+	"log"
+	"strings"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const (
+	busPrefix   = "org.mpris.MediaPlayer2."
+	playerPath  = "/org/mpris/MediaPlayer2"
+	playerIface = "org.mpris.MediaPlayer2.Player"
+)
+
+// players tracks the MPRIS players on the bus, ordered by when they last
+// started playing.
+type players struct {
+	conn *dbus.Conn
+	// owners maps the unique bus names that signals are sent from to the
+	// well known names of the players.
+	owners map[string]string
+	// recent holds the well known names, the most recently active first.
+	recent []string
+}
+
+// listPlayers returns the players currently on the bus, the playing ones
+// first.
+func listPlayers(conn *dbus.Conn) (*players, error) {
+	p := &players{conn: conn, owners: map[string]string{}}
+	var names []string
+	if err := conn.BusObject().Call("org.freedesktop.DBus.ListNames", 0).Store(&names); err != nil {
+		return nil, err
+	}
+	var idle []string
+	for _, name := range names {
+		if !strings.HasPrefix(name, busPrefix) {
+			continue
+		}
+		var owner string
+		if err := conn.BusObject().Call("org.freedesktop.DBus.GetNameOwner", 0, name).Store(&owner); err != nil {
+			continue
+		}
+		p.owners[owner] = name
+		if p.playing(name) {
+			p.recent = append(p.recent, name)
+		} else {
+			idle = append(idle, name)
+		}
+	}
+	p.recent = append(p.recent, idle...)
+	return p, nil
+}
+
+// playing reports whether the player is currently playing.
+func (p *players) playing(name string) bool {
+	status, err := p.conn.Object(name, playerPath).GetProperty(playerIface + ".PlaybackStatus")
+	if err != nil {
+		log.Printf("failed to get playback status of %s: %v", name, err)
+		return false
+	}
+	return status.Value() == "Playing"
+}
+
+// activate moves a player to the front.
+func (p *players) activate(name string) {
+	p.remove(name)
+	p.recent = append([]string{name}, p.recent...)
+}
+
+func (p *players) remove(name string) {
+	for n, recent := range p.recent {
+		if recent == name {
+			p.recent = append(p.recent[:n], p.recent[n+1:]...)
+			return
+		}
+	}
+}
+
+// handle updates the players from a signal.
+func (p *players) handle(sig *dbus.Signal) {
+	switch sig.Name {
+	case "org.freedesktop.DBus.NameOwnerChanged":
+		var name, oldOwner, newOwner string
+		if dbus.Store(sig.Body, &name, &oldOwner, &newOwner) != nil {
+			return
+		}
+		delete(p.owners, oldOwner)
+		if newOwner == "" {
+			p.remove(name)
+			return
+		}
+		p.owners[newOwner] = name
+		// A new player only takes over if it starts out playing.
+		if p.playing(name) {
+			p.activate(name)
+		} else {
+			p.recent = append(p.recent, name)
+		}
+	case "org.freedesktop.DBus.Properties.PropertiesChanged":
+		var iface string
+		var changed map[string]dbus.Variant
+		var invalidated []string
+		if dbus.Store(sig.Body, &iface, &changed, &invalidated) != nil {
+			return
+		}
+		name, ok := p.owners[sig.Sender]
+		if ok && iface == playerIface && changed["PlaybackStatus"].Value() == "Playing" {
+			p.activate(name)
+		}
+	}
+}
+
+// watch subscribes to the signals handled by handle.
+func watch(conn *dbus.Conn, signals chan<- *dbus.Signal) error {
+	conn.Signal(signals)
+	err := conn.AddMatchSignal(
+		dbus.WithMatchInterface("org.freedesktop.DBus"),
+		dbus.WithMatchMember("NameOwnerChanged"),
+		dbus.WithMatchArg0Namespace(strings.TrimSuffix(busPrefix, ".")),
+	)
+	if err != nil {
+		return err
+	}
+	return conn.AddMatchSignal(
+		dbus.WithMatchObjectPath(playerPath),
+		dbus.WithMatchInterface("org.freedesktop.DBus.Properties"),
+		dbus.WithMatchMember("PropertiesChanged"),
+		dbus.WithMatchArg(0, playerIface),
+	)
+}
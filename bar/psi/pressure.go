@@ -0,0 +1,92 @@
+// Package psi reads the pressure stall information of the kernel from
+// /proc/pressure.
+package psi
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Stall is the share of time tasks were stalled on a resource.
+type Stall struct {
+	// Avg10, Avg60 and Avg300 are the percentages of time stalled over the
+	// last 10, 60 and 300 seconds.
+	Avg10, Avg60, Avg300 float64
+	// Total is the cumulative stall time.
+	Total time.Duration
+}
+
+// Pressure is the pressure on a single resource.
+type Pressure struct {
+	// Some is the time at least one task was stalled.
+	Some Stall
+	// Full is the time all non-idle tasks were stalled at once. It is
+	// always zero for the cpu at the system level.
+	Full Stall
+}
+
+// Parse parses the contents of a pressure file.
+func Parse(r io.Reader) (Pressure, error) {
+	var p Pressure
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		var stall *Stall
+		switch fields[0] {
+		case "some":
+			stall = &p.Some
+		case "full":
+			stall = &p.Full
+		default:
+			continue
+		}
+		for _, field := range fields[1:] {
+			key, value, ok := strings.Cut(field, "=")
+			if !ok {
+				return Pressure{}, fmt.Errorf("malformed field %q", field)
+			}
+			var err error
+			switch key {
+			case "avg10":
+				stall.Avg10, err = strconv.ParseFloat(value, 64)
+			case "avg60":
+				stall.Avg60, err = strconv.ParseFloat(value, 64)
+			case "avg300":
+				stall.Avg300, err = strconv.ParseFloat(value, 64)
+			case "total":
+				var us uint64
+				us, err = strconv.ParseUint(value, 10, 64)
+				stall.Total = time.Duration(us) * time.Microsecond
+			}
+			if err != nil {
+				return Pressure{}, fmt.Errorf("%s: %w", key, err)
+			}
+		}
+	}
+	return p, s.Err()
+}
+
+// ReadFile reads the pressure from the given file.
+func ReadFile(path string) (Pressure, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Pressure{}, err
+	}
+	defer f.Close()
+	return Parse(f)
+}
+
+// Read reads the pressure of a resource ("cpu", "io" or "memory") from
+// /proc/pressure.
+func Read(resource string) (Pressure, error) {
+	return ReadFile(filepath.Join("/proc/pressure", resource))
+}
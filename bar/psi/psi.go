@@ -0,0 +1,203 @@
+package psi
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/base/value"
+	"barista.run/colors"
+	"barista.run/outputs"
+	"barista.run/timing"
+)
+
+// Resources are the resources pressure is reported for.
+var Resources = []string{"cpu", "io", "memory"}
+
+// Info holds the pressure of all resources.
+type Info struct {
+	CPU, IO, Memory Pressure
+	// FullThreshold is the configured avg10 percentage of full stalls above
+	// which the output is urgent.
+	FullThreshold float64
+}
+
+// Get returns the pressure of the given resource.
+func (i Info) Get(resource string) Pressure {
+	switch resource {
+	case "cpu":
+		return i.CPU
+	case "io":
+		return i.IO
+	default:
+		return i.Memory
+	}
+}
+
+// Worst returns the resource with the highest avg10 of some stalls.
+func (i Info) Worst() (string, Pressure) {
+	worst := Resources[0]
+	for _, resource := range Resources[1:] {
+		if i.Get(resource).Some.Avg10 > i.Get(worst).Some.Avg10 {
+			worst = resource
+		}
+	}
+	return worst, i.Get(worst)
+}
+
+// Urgent reports whether the full stalls of any resource exceed the
+// threshold.
+func (i Info) Urgent() bool {
+	for _, resource := range Resources {
+		if i.Get(resource).Full.Avg10 >= i.FullThreshold {
+			return true
+		}
+	}
+	return false
+}
+
+// Module represents a pressure stall information barista module.
+type Module struct {
+	procRoot      string
+	fullThreshold float64
+	trigger       *trigger
+	scheduler     *timing.Scheduler
+	outputFunc    value.Value // of func(Info) bar.Output
+}
+
+// trigger is a psi trigger firing when tasks are stalled for at least stall
+// within window.
+type trigger struct {
+	stall, window time.Duration
+}
+
+// New constructs a pressure module showing the worst resource.
+func New() *Module {
+	m := &Module{
+		procRoot:      "/proc",
+		fullThreshold: 10,
+		scheduler:     timing.NewScheduler().Every(5 * time.Second),
+	}
+	m.Output(func(i Info) bar.Output {
+		resource, p := i.Worst()
+		out := outputs.Textf("%s %.0f/%.0f%%", resource, p.Some.Avg10, p.Some.Avg60)
+		switch {
+		case i.Urgent():
+			out.Color(colors.Scheme("bad")).Urgent(true)
+		case p.Some.Avg10 >= 40:
+			out.Color(colors.Scheme("bad"))
+		case p.Some.Avg10 >= 10:
+			out.Color(colors.Scheme("degraded"))
+		}
+		return out
+	})
+	return m
+}
+
+// FullThreshold sets the avg10 percentage of full stalls above which the
+// output is urgent.
+func (m *Module) FullThreshold(percent float64) *Module {
+	m.fullThreshold = percent
+	return m
+}
+
+// Trigger additionally updates the module as soon as tasks are stalled on any
+// resource for at least stall within window, instead of only at the refresh
+// interval. Unprivileged users need a kernel >= 6.4 and a window that is a
+// multiple of 2s; if the trigger cannot be created, the module only polls.
+func (m *Module) Trigger(stall, window time.Duration) *Module {
+	m.trigger = &trigger{stall, window}
+	return m
+}
+
+// ProcRoot sets the directory pressure is read from, "/proc" by default.
+func (m *Module) ProcRoot(root string) *Module {
+	m.procRoot = root
+	return m
+}
+
+// RefreshInterval configures the polling frequency.
+func (m *Module) RefreshInterval(interval time.Duration) *Module {
+	m.scheduler.Every(interval)
+	return m
+}
+
+// Output sets the output format for the module.
+func (m *Module) Output(outputFunc func(Info) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+// Stream starts the module.
+func (m *Module) Stream(sink bar.Sink) {
+	var triggered <-chan struct{}
+	if m.trigger != nil {
+		ch, stop := m.watch()
+		defer stop()
+		triggered = ch
+	}
+	info, err := m.read()
+	outputFunc := m.outputFunc.Get().(func(Info) bar.Output)
+	nextOutputFunc, done := m.outputFunc.Subscribe()
+	defer done()
+	for {
+		if sink.Error(err) {
+			return
+		}
+		sink.Output(outputFunc(info))
+		select {
+		case <-m.scheduler.C:
+			info, err = m.read()
+		case <-triggered:
+			info, err = m.read()
+		case <-nextOutputFunc:
+			outputFunc = m.outputFunc.Get().(func(Info) bar.Output)
+		}
+	}
+}
+
+func (m *Module) read() (Info, error) {
+	info := Info{FullThreshold: m.fullThreshold}
+	for _, resource := range Resources {
+		p, err := ReadFile(m.path(resource))
+		if err != nil {
+			return Info{}, err
+		}
+		switch resource {
+		case "cpu":
+			info.CPU = p
+		case "io":
+			info.IO = p
+		default:
+			info.Memory = p
+		}
+	}
+	return info, nil
+}
+
+func (m *Module) path(resource string) string {
+	return filepath.Join(m.procRoot, "pressure", resource)
+}
+
+// watch creates a trigger for each resource and returns a channel receiving
+// a value whenever one fires, and a function removing the triggers.
+func (m *Module) watch() (<-chan struct{}, func()) {
+	triggered := make(chan struct{}, 1)
+	var stops []func()
+	for _, resource := range Resources {
+		spec := fmt.Sprintf("some %d %d", m.trigger.stall.Microseconds(), m.trigger.window.Microseconds())
+		stop, err := watchTrigger(m.path(resource), spec, triggered)
+		if err != nil {
+			log.Printf("failed to create %s pressure trigger, polling instead: %v", resource, err)
+			continue
+		}
+		stops = append(stops, stop)
+	}
+	return triggered, func() {
+		for _, stop := range stops {
+			stop()
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package psi
+
+import (
+	"errors"
+
+	"golang.org/x/sys/unix"
+)
+
+// watchTrigger registers a trigger in the given pressure file and sends to
+// triggered whenever it fires, without blocking. The trigger lives as long
+// as the file is open, so the returned function closes it.
+func watchTrigger(path, spec string, triggered chan<- struct{}) (func(), error) {
+	fd, err := unix.Open(path, unix.O_RDWR|unix.O_NONBLOCK|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, err
+	}
+	// The kernel expects the trailing NUL.
+	if _, err := unix.Write(fd, append([]byte(spec), 0)); err != nil {
+		unix.Close(fd)
+		return nil, err
+	}
+	// Closing the file while polling it is racy, so a pipe wakes up the
+	// poll instead.
+	var wake [2]int
+	if err := unix.Pipe2(wake[:], unix.O_CLOEXEC); err != nil {
+		unix.Close(fd)
+		return nil, err
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		fds := []unix.PollFd{
+			{Fd: int32(fd), Events: unix.POLLPRI},
+			{Fd: int32(wake[0]), Events: unix.POLLIN},
+		}
+		for {
+			_, err := unix.Poll(fds, -1)
+			if errors.Is(err, unix.EINTR) {
+				continue
+			}
+			if err != nil || fds[1].Revents != 0 {
+				return
+			}
+			if fds[0].Revents&unix.POLLERR != 0 {
+				// The pressure file went away, e.g. the cgroup was removed.
+				return
+			}
+			if fds[0].Revents&unix.POLLPRI != 0 {
+				select {
+				case triggered <- struct{}{}:
+				default:
+				}
+			}
+		}
+	}()
+	return func() {
+		unix.Close(wake[1])
+		<-done
+		unix.Close(wake[0])
+		unix.Close(fd)
+	}, nil
+}
@@ -0,0 +1,133 @@
+// Package sparkline keeps a rolling history of values and renders it as a
+// unicode sparkline or a pango bar.
+package sparkline
+
+import (
+	"strings"
+
+	"barista.run/pango"
+
+	"github.com/dustin/go-humanize"
+	"github.com/martinlindhe/unit"
+)
+
+var ticks = []rune("▁▂▃▄▅▆▇█")
+
+// Style selects how a history is rendered.
+type Style int
+
+const (
+	// Sparkline renders every value of the history as a unicode block.
+	Sparkline Style = iota
+	// Bar renders the latest value as a horizontal bar scaled to the
+	// maximum of the history.
+	Bar
+	// None does not render the history at all.
+	None
+)
+
+// ParseStyle converts "spark", "bar" or "none" to a Style.
+func ParseStyle(name string) Style {
+	switch name {
+	case "bar":
+		return Bar
+	case "none":
+		return None
+	default:
+		return Sparkline
+	}
+}
+
+// History is a fixed size rolling history of values. It is not safe for
+// concurrent use, which is fine for output funcs since barista calls them
+// from the module's Stream goroutine.
+type History struct {
+	values []float64
+	size   int
+}
+
+// New creates a history holding the last size values.
+func New(size int) *History {
+	return &History{size: size}
+}
+
+// Add appends a value, dropping the oldest one if the history is full.
+func (h *History) Add(value float64) *History {
+	h.values = append(h.values, value)
+	if len(h.values) > h.size {
+		h.values = h.values[len(h.values)-h.size:]
+	}
+	return h
+}
+
+// Len returns the number of values in the history.
+func (h *History) Len() int {
+	return len(h.values)
+}
+
+// Max returns the largest value in the history.
+func (h *History) Max() float64 {
+	var max float64
+	for _, v := range h.values {
+		if v > max {
+			max = v
+		}
+	}
+	return max
+}
+
+// Sparkline renders the history as unicode blocks, padded to the size of the
+// history so that the segment does not change its width.
+func (h *History) Sparkline() string {
+	padding := strings.Repeat(string(ticks[0]), h.size-len(h.values))
+	return padding + Blocks(h.values, h.Max())
+}
+
+// Blocks renders each value as a unicode block whose height is relative to
+// max, e.g. a per-core usage graph.
+func Blocks(values []float64, max float64) string {
+	var b strings.Builder
+	for _, v := range values {
+		index := 0
+		if max > 0 {
+			index = int(v / max * float64(len(ticks)-1))
+		}
+		if index >= len(ticks) {
+			index = len(ticks) - 1
+		} else if index < 0 {
+			index = 0
+		}
+		b.WriteRune(ticks[index])
+	}
+	return b.String()
+}
+
+// Bar renders the latest value relative to the maximum of the history as a
+// bar of the given width, with the unfilled part dimmed.
+func (h *History) Bar(width int) *pango.Node {
+	filled := 0
+	if max := h.Max(); max > 0 && len(h.values) > 0 {
+		filled = int(h.values[len(h.values)-1]/max*float64(width) + 0.5)
+	}
+	return pango.New(
+		pango.Text(strings.Repeat("█", filled)),
+		pango.Text(strings.Repeat("█", width-filled)).Alpha(0.3),
+	)
+}
+
+// Render renders the history in the given style.
+func (h *History) Render(style Style) *pango.Node {
+	switch style {
+	case Bar:
+		return h.Bar(h.size)
+	case None:
+		return pango.New()
+	default:
+		return pango.Text(h.Sparkline())
+	}
+}
+
+// Rate formats a data rate with automatically scaled SI units, e.g. "1.2 MB/s".
+func Rate(rate unit.Datarate) string {
+	return humanize.Bytes(uint64(rate/unit.BytePerSecond)) + "/s"
+}
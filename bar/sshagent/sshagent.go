@@ -0,0 +1,202 @@
+// Package sshagent provides an indicator for the identities loaded into the
+// ssh agent.
+package sshagent
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/base/value"
+	"barista.run/colors"
+	"barista.run/format"
+	"barista.run/outputs"
+	"barista.run/timing"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// Cert is a certificate loaded into the agent.
+type Cert struct {
+	// Comment is the comment the certificate was added with, usually the
+	// path of the certificate file.
+	Comment string
+	*ssh.Certificate
+}
+
+// Remaining returns the time until the certificate expires, or 0 once it
+// has expired.
+func (c Cert) Remaining() time.Duration {
+	if c.ValidBefore == ssh.CertTimeInfinity {
+		return time.Duration(1<<63 - 1)
+	}
+	remaining := time.Until(time.Unix(int64(c.ValidBefore), 0))
+	if remaining < 0 {
+		return 0
+	}
+	return remaining
+}
+
+// RemainingFraction returns the fraction of the validity period that is
+// left, from 1 for a certificate that just became valid to 0 once expired.
+func (c Cert) RemainingFraction() float64 {
+	if c.ValidBefore == ssh.CertTimeInfinity {
+		return 1
+	}
+	total := float64(c.ValidBefore) - float64(c.ValidAfter)
+	if total <= 0 {
+		return 0
+	}
+	return c.Remaining().Seconds() / total
+}
+
+// Info describes the identities currently held by the ssh agent.
+type Info struct {
+	// Keys is the number of plain keys loaded into the agent.
+	Keys int
+	// Certs holds all certificates loaded into the agent, sorted so that the
+	// certificate expiring soonest comes first.
+	Certs []Cert
+	// Locked is set if the agent refused to list its identities because it
+	// is locked. The OpenSSH agent instead reports no identities while it is
+	// locked, which cannot be told apart from an empty agent.
+	Locked bool
+}
+
+// Identities returns the total number of identities, keys and certificates.
+func (i Info) Identities() int {
+	return i.Keys + len(i.Certs)
+}
+
+// Empty reports whether the agent holds no identities, which includes a
+// locked OpenSSH agent.
+func (i Info) Empty() bool {
+	return !i.Locked && i.Identities() == 0
+}
+
+// Module represents a sshagent barista module that shows the number of
+// loaded identities and the soonest certificate expiry.
+type Module struct {
+	socketPath string
+	timeout    time.Duration
+	scheduler  *timing.Scheduler
+	outputFunc value.Value // of func(Info) bar.Output
+}
+
+// ForSocket constructs a sshagent module for the agent listening on the
+// given unix socket.
+func ForSocket(socketPath string) *Module {
+	m := &Module{
+		socketPath: socketPath,
+		timeout:    5 * time.Second,
+		scheduler:  timing.NewScheduler().Every(10 * time.Second),
+	}
+	m.Output(func(i Info) bar.Output {
+		if i.Locked {
+			return outputs.Text("[agent: locked]").Color(colors.Scheme("bad"))
+		}
+		if i.Empty() {
+			return outputs.Text("[agent: no keys]").Color(colors.Scheme("degraded"))
+		}
+		if len(i.Certs) == 0 {
+			return outputs.Textf("[agent: %d]", i.Keys)
+		}
+		return outputs.Textf("[agent: %d, cert %s]", i.Identities(), format.Duration(i.Certs[0].Remaining()))
+	})
+	return m
+}
+
+// New constructs a new sshagent module for the agent in $SSH_AUTH_SOCK.
+func New() *Module {
+	return ForSocket(os.Getenv("SSH_AUTH_SOCK"))
+}
+
+// Output sets the output format for the module.
+func (m *Module) Output(outputFunc func(Info) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+// Timeout sets how long to wait for the agent to answer, 5 seconds by
+// default. An agent blocked on a pinentry prompt may not answer at all.
+func (m *Module) Timeout(timeout time.Duration) *Module {
+	m.timeout = timeout
+	return m
+}
+
+// RefreshInterval configures the polling frequency.
+func (m *Module) RefreshInterval(interval time.Duration) *Module {
+	m.scheduler.Every(interval)
+	return m
+}
+
+// Stream starts the module.
+func (m *Module) Stream(sink bar.Sink) {
+	outputFunc := m.outputFunc.Get().(func(Info) bar.Output)
+	nextOutputFunc, done := m.outputFunc.Subscribe()
+	defer done()
+	info, err := m.query()
+	for {
+		// Errors are shown but polling continues, since the agent might be
+		// started later.
+		if err != nil {
+			sink.Error(err)
+		} else {
+			sink.Output(outputFunc(info))
+		}
+		select {
+		case <-m.scheduler.C:
+			info, err = m.query()
+		case <-nextOutputFunc:
+			outputFunc = m.outputFunc.Get().(func(Info) bar.Output)
+		}
+	}
+}
+
+// errFailure is the error the agent client returns if the agent answers the
+// listing with SSH_AGENT_FAILURE, as agents do while they are locked. The
+// client does not export it, so it is matched by its message.
+const errFailure = "agent: failed to list keys"
+
+// query connects to the agent and lists its identities. A new connection is
+// used for each query, since the agent may be restarted at any time.
+func (m *Module) query() (Info, error) {
+	if m.socketPath == "" {
+		return Info{}, fmt.Errorf("SSH_AUTH_SOCK is not set")
+	}
+	conn, err := net.DialTimeout("unix", m.socketPath, m.timeout)
+	if err != nil {
+		return Info{}, err
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(time.Now().Add(m.timeout)); err != nil {
+		return Info{}, err
+	}
+	keys, err := agent.NewClient(conn).List()
+	if err != nil && err.Error() == errFailure {
+		return Info{Locked: true}, nil
+	}
+	if err != nil {
+		return Info{}, err
+	}
+	var info Info
+	for _, key := range keys {
+		pub, err := ssh.ParsePublicKey(key.Blob)
+		if err != nil {
+			return Info{}, err
+		}
+		if cert, ok := pub.(*ssh.Certificate); ok {
+			info.Certs = append(info.Certs, Cert{Comment: key.Comment, Certificate: cert})
+		} else {
+			info.Keys++
+		}
+	}
+	sort.SliceStable(info.Certs, func(i, j int) bool {
+		return info.Certs[i].ValidBefore < info.Certs[j].ValidBefore
+	})
+	return info, nil
+}
@@ -0,0 +1,117 @@
+package sshagent
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"io"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// fakeAgent listens on a socket and serves every connection, returning the
+// path of the socket.
+func fakeAgent(t *testing.T, serve func(net.Conn)) string {
+	path := filepath.Join(t.TempDir(), "agent.sock")
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				serve(conn)
+			}()
+		}
+	}()
+	return path
+}
+
+func TestQuery(t *testing.T) {
+	keyring := agent.NewKeyring()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := keyring.Add(agent.AddedKey{PrivateKey: key}); err != nil {
+		t.Fatal(err)
+	}
+	pub, certKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sshPub, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert := &ssh.Certificate{
+		Key:         sshPub,
+		CertType:    ssh.UserCert,
+		ValidBefore: uint64(time.Now().Add(time.Hour).Unix()),
+	}
+	if err := cert.SignCert(rand.Reader, signer); err != nil {
+		t.Fatal(err)
+	}
+	if err := keyring.Add(agent.AddedKey{PrivateKey: certKey, Certificate: cert, Comment: "id-cert.pub"}); err != nil {
+		t.Fatal(err)
+	}
+	path := fakeAgent(t, func(conn net.Conn) { _ = agent.ServeAgent(keyring, conn) })
+
+	info, err := ForSocket(path).query()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Locked || info.Keys != 1 || len(info.Certs) != 1 || info.Certs[0].Comment != "id-cert.pub" {
+		t.Errorf("got %+v, want a key and a certificate", info)
+	}
+}
+
+func TestQueryLocked(t *testing.T) {
+	// Agents like gpg-agent answer requests with SSH_AGENT_FAILURE while
+	// they are locked.
+	path := fakeAgent(t, func(conn net.Conn) {
+		var length uint32
+		if binary.Read(conn, binary.BigEndian, &length) != nil {
+			return
+		}
+		if _, err := io.CopyN(io.Discard, conn, int64(length)); err != nil {
+			return
+		}
+		_, _ = conn.Write([]byte{0, 0, 0, 1, 5})
+	})
+	info, err := ForSocket(path).query()
+	if err != nil {
+		t.Fatalf("got error %v, want a locked agent", err)
+	}
+	if !info.Locked || info.Empty() {
+		t.Errorf("got %+v, want a locked agent", info)
+	}
+}
+
+func TestQueryTimeout(t *testing.T) {
+	hung := make(chan struct{})
+	defer close(hung)
+	path := fakeAgent(t, func(net.Conn) { <-hung })
+	start := time.Now()
+	if _, err := ForSocket(path).Timeout(100 * time.Millisecond).query(); err == nil {
+		t.Error("no error from an agent that does not answer")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("query took %v with a timeout of 100ms", elapsed)
+	}
+}
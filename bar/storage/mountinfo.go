@@ -0,0 +1,183 @@
+package storage
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Mount is a mounted filesystem as listed in /proc/self/mountinfo.
+type Mount struct {
+	// Device is the "major:minor" of the filesystem, which is the same for
+	// all mounts of one filesystem, e.g. btrfs subvolumes and bind mounts.
+	Device string
+	// Point is the mountpoint.
+	Point string
+	// FSType is the filesystem type, e.g. "ext4", "btrfs" or "zfs".
+	FSType string
+	// Source is the mounted device, e.g. "/dev/nvme0n1p2" or a zfs dataset.
+	Source string
+}
+
+// pseudoFSTypes are filesystems without a backing device that are still
+// listed with a source.
+var pseudoFSTypes = map[string]bool{
+	"squashfs": true, // snaps and live media are always full
+	"iso9660":  true,
+	"udf":      true,
+}
+
+// Real reports whether the mount is backed by a block device or a zfs pool.
+func (m Mount) Real() bool {
+	if pseudoFSTypes[m.FSType] {
+		return false
+	}
+	return m.FSType == "zfs" || strings.HasPrefix(m.Source, "/dev/")
+}
+
+// filesystem identifies the filesystem of the mount. The datasets of a zfs
+// pool are separate filesystems, but their usage is reported for the pool.
+func (m Mount) filesystem() string {
+	if m.FSType == "zfs" {
+		pool, _, _ := strings.Cut(m.Source, "/")
+		return "zfs:" + pool
+	}
+	return m.Device
+}
+
+// Matches reports whether the mountpoint or the filesystem type matches the
+// given pattern (see filepath.Match).
+func (m Mount) Matches(pattern string) bool {
+	if ok, _ := filepath.Match(pattern, m.Point); ok {
+		return true
+	}
+	ok, _ := filepath.Match(pattern, m.FSType)
+	return ok
+}
+
+// ParseMountinfo parses the format of /proc/[pid]/mountinfo.
+func ParseMountinfo(r io.Reader) ([]Mount, error) {
+	var mounts []Mount
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		// 36 35 98:0 /mnt1 /mnt2 rw,noatime master:1 - ext3 /dev/root rw
+		before, after, ok := strings.Cut(s.Text(), " - ")
+		if !ok {
+			return nil, fmt.Errorf("malformed mountinfo line %q", s.Text())
+		}
+		fields := strings.Fields(before)
+		fsFields := strings.Fields(after)
+		if len(fields) < 5 || len(fsFields) < 2 {
+			return nil, fmt.Errorf("malformed mountinfo line %q", s.Text())
+		}
+		mounts = append(mounts, Mount{
+			Device: fields[2],
+			Point:  unescape(fields[4]),
+			FSType: fsFields[0],
+			Source: unescape(fsFields[1]),
+		})
+	}
+	return mounts, s.Err()
+}
+
+// unescape replaces the octal escapes the kernel uses for spaces, tabs,
+// newlines and backslashes.
+func unescape(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+4 <= len(s) {
+			if c, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(c))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+func readMountinfo(mountinfo string) ([]Mount, error) {
+	f, err := os.Open(mountinfo)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseMountinfo(f)
+}
+
+// Lookup returns the mounts of the given mountpoints from the given
+// mountinfo file.
+func Lookup(mountinfo string, points []string) ([]Mount, error) {
+	all, err := readMountinfo(mountinfo)
+	if err != nil {
+		return nil, err
+	}
+	var mounts []Mount
+	for _, point := range points {
+		point = filepath.Clean(point)
+		var found *Mount
+		// Later mounts on the same point hide earlier ones.
+		for i := range all {
+			if all[i].Point == point {
+				found = &all[i]
+			}
+		}
+		if found == nil {
+			return nil, fmt.Errorf("%s is not a mountpoint", point)
+		}
+		mounts = append(mounts, *found)
+	}
+	return mounts, nil
+}
+
+// Discover returns the real filesystems from the given mountinfo file, each
+// only once, that match any of the include patterns (all if there are none)
+// and none of the exclude patterns.
+func Discover(mountinfo string, include, exclude []string) ([]Mount, error) {
+	all, err := readMountinfo(mountinfo)
+	if err != nil {
+		return nil, err
+	}
+	var mounts []Mount
+	seen := map[string]bool{}
+	for _, m := range Filter(all, include, exclude) {
+		if !m.Real() || seen[m.filesystem()] {
+			continue
+		}
+		seen[m.filesystem()] = true
+		mounts = append(mounts, m)
+	}
+	return mounts, nil
+}
+
+// Filter returns the mounts that match any of the include patterns (all if
+// there are none) and none of the exclude patterns.
+func Filter(mounts []Mount, include, exclude []string) []Mount {
+	var filtered []Mount
+	for _, m := range mounts {
+		if matchesAny(m, include, true) && !matchesAny(m, exclude, false) {
+			filtered = append(filtered, m)
+		}
+	}
+	return filtered
+}
+
+func matchesAny(m Mount, patterns []string, empty bool) bool {
+	if len(patterns) == 0 {
+		return empty
+	}
+	for _, pattern := range patterns {
+		if m.Matches(pattern) {
+			return true
+		}
+	}
+	return false
+}
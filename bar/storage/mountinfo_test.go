@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const mountinfo = "testdata/mountinfo"
+
+func points(mounts []Mount) []string {
+	var points []string
+	for _, m := range mounts {
+		points = append(points, m.Point)
+	}
+	return points
+}
+
+func TestDiscover(t *testing.T) {
+	for _, tc := range []struct {
+		name             string
+		include, exclude []string
+		want             []string
+	}{
+		{"all", nil, nil, []string{"/", "/boot", "/home", "/tank"}},
+		{"include type", []string{"btrfs", "zfs"}, nil, []string{"/home", "/tank"}},
+		{"include point", []string{"/b*"}, nil, []string{"/boot"}},
+		{"exclude", nil, []string{"vfat", "/tank"}, []string{"/", "/home", "/tank/backup"}},
+		{"include and exclude", []string{"zfs"}, []string{"/tank"}, []string{"/tank/backup"}},
+		{"pseudo filesystems", []string{"tmpfs", "squashfs"}, nil, nil},
+	} {
+		mounts, err := Discover(mountinfo, tc.include, tc.exclude)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := points(mounts); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: got %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestLookup(t *testing.T) {
+	mounts, err := Lookup(mountinfo, []string{"/home/user/My Media/", "/run/user/1000"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Mount{
+		{Device: "0:45", Point: "/home/user/My Media", FSType: "btrfs", Source: "/dev/sda1"},
+		{Device: "0:60", Point: "/run/user/1000", FSType: "tmpfs", Source: "tmpfs"},
+	}
+	if !reflect.DeepEqual(mounts, want) {
+		t.Errorf("got %+v, want %+v", mounts, want)
+	}
+	if _, err := Lookup(mountinfo, []string{"/home/user"}); err == nil {
+		t.Error("got no error for a directory that is not a mountpoint")
+	}
+}
+
+func TestFilterMountpoints(t *testing.T) {
+	// Given mountpoints are filtered as well, but unlike discovered ones
+	// they may be pseudo filesystems.
+	mounts, err := Lookup(mountinfo, []string{"/", "/boot", "/run/user/1000"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"/run/user/1000"}
+	if got := points(Filter(mounts, nil, []string{"vfat", "/"})); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	_, err = New("/boot").Roots(mountinfo, t.TempDir()).Include("ext4").read()
+	if err == nil || !strings.Contains(err.Error(), "no mounts matching") {
+		t.Errorf("got %v for a filtered out mountpoint, want no mounts matching", err)
+	}
+}
@@ -0,0 +1,143 @@
+// Package storage provides a disk space indicator for multiple mounts that
+// collapses into the one closest to running full.
+package storage
+
+import (
+	"fmt"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/base/value"
+	"barista.run/format"
+	"barista.run/outputs"
+	"barista.run/timing"
+)
+
+// Info holds the usage of all mounts.
+type Info struct {
+	// Mounts holds the usage of each mount in mountinfo order. Mounts that
+	// could not be read, e.g. a stale network mount, have Err set.
+	Mounts []Usage
+	// Usage is the readable mount with the smallest available fraction.
+	Usage
+}
+
+// Module represents a storage barista module.
+type Module struct {
+	mountinfo  string
+	sysfsRoot  string
+	points     []string
+	include    []string
+	exclude    []string
+	scheduler  *timing.Scheduler
+	outputFunc value.Value // of func(Info) bar.Output
+}
+
+// New constructs a storage module for the given mountpoints. Without
+// mountpoints, all mounted filesystems backed by a block device or zfs pool
+// are discovered on each refresh. Include and Exclude filter both.
+func New(points ...string) *Module {
+	m := &Module{
+		mountinfo: "/proc/self/mountinfo",
+		sysfsRoot: "/sys",
+		points:    points,
+		scheduler: timing.NewScheduler().Every(5 * time.Second),
+	}
+	m.Output(func(i Info) bar.Output {
+		return outputs.Textf("%s %s", i.Point, format.IBytesize(i.Available))
+	})
+	return m
+}
+
+// Include restricts the mounts to those whose mountpoint or filesystem type
+// matches any of the patterns (see filepath.Match).
+func (m *Module) Include(patterns ...string) *Module {
+	m.include = patterns
+	return m
+}
+
+// Exclude skips mounts whose mountpoint or filesystem type matches any of the
+// patterns (see filepath.Match).
+func (m *Module) Exclude(patterns ...string) *Module {
+	m.exclude = patterns
+	return m
+}
+
+// Roots sets the mountinfo file and the sysfs directory to read,
+// "/proc/self/mountinfo" and "/sys" by default.
+func (m *Module) Roots(mountinfo, sysfsRoot string) *Module {
+	m.mountinfo = mountinfo
+	m.sysfsRoot = sysfsRoot
+	return m
+}
+
+// RefreshInterval configures the polling frequency.
+func (m *Module) RefreshInterval(interval time.Duration) *Module {
+	m.scheduler.Every(interval)
+	return m
+}
+
+// Output sets the output format for the module.
+func (m *Module) Output(outputFunc func(Info) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+// Stream starts the module.
+func (m *Module) Stream(sink bar.Sink) {
+	info, err := m.read()
+	outputFunc := m.outputFunc.Get().(func(Info) bar.Output)
+	nextOutputFunc, done := m.outputFunc.Subscribe()
+	defer done()
+	for {
+		if sink.Error(err) {
+			return
+		}
+		sink.Output(outputFunc(info))
+		select {
+		case <-m.scheduler.C:
+			info, err = m.read()
+		case <-nextOutputFunc:
+			outputFunc = m.outputFunc.Get().(func(Info) bar.Output)
+		}
+	}
+}
+
+func (m *Module) read() (Info, error) {
+	var mounts []Mount
+	var err error
+	if len(m.points) > 0 {
+		mounts, err = Lookup(m.mountinfo, m.points)
+		mounts = Filter(mounts, m.include, m.exclude)
+	} else {
+		mounts, err = Discover(m.mountinfo, m.include, m.exclude)
+	}
+	if err != nil {
+		return Info{}, err
+	}
+	if len(mounts) == 0 {
+		return Info{}, fmt.Errorf("no mounts matching %q excluding %q", m.include, m.exclude)
+	}
+	var info Info
+	var firstErr error
+	found := false
+	for _, mount := range mounts {
+		u, err := usage(mount, m.sysfsRoot)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			info.Mounts = append(info.Mounts, Usage{Mount: mount, Err: err})
+			continue
+		}
+		info.Mounts = append(info.Mounts, u)
+		if !found || u.AvailFrac() < info.AvailFrac() {
+			info.Usage = u
+			found = true
+		}
+	}
+	if !found {
+		return Info{}, firstErr
+	}
+	return info, nil
+}
@@ -0,0 +1,156 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/martinlindhe/unit"
+	"golang.org/x/sys/unix"
+)
+
+// Usage is the space on a mounted filesystem.
+type Usage struct {
+	Mount
+	Total     unit.Datasize
+	Available unit.Datasize
+	// Err is set if the space could not be read.
+	Err error
+}
+
+// Used returns the used space.
+func (u Usage) Used() unit.Datasize {
+	return u.Total - u.Available
+}
+
+// AvailFrac returns the available fraction of the total space.
+func (u Usage) AvailFrac() float64 {
+	if u.Total == 0 {
+		return 0
+	}
+	return float64(u.Available / u.Total)
+}
+
+// usage returns the space on the mount. For btrfs and zfs the statfs numbers
+// are misleading, so their own accounting is used if available.
+func usage(m Mount, sysfsRoot string) (Usage, error) {
+	switch m.FSType {
+	case "btrfs":
+		if u, err := btrfsUsage(m, sysfsRoot); err == nil {
+			return u, nil
+		}
+	case "zfs":
+		if u, err := zfsUsage(m); err == nil {
+			return u, nil
+		}
+	}
+	return statfsUsage(m)
+}
+
+func statfsUsage(m Mount) (Usage, error) {
+	var st unix.Statfs_t
+	if err := unix.Statfs(m.Point, &st); err != nil {
+		return Usage{}, &os.PathError{Op: "statfs", Path: m.Point, Err: err}
+	}
+	bsize := unit.Datasize(st.Bsize) * unit.Byte
+	return Usage{
+		Mount:     m,
+		Total:     unit.Datasize(st.Blocks) * bsize,
+		Available: unit.Datasize(st.Bavail) * bsize,
+	}, nil
+}
+
+// btrfsUsage estimates the space like `btrfs filesystem usage`: statfs
+// ignores unallocated space and the data profile, e.g. halving the
+// available space for raid1. Only sysfs is read, so no root is needed.
+func btrfsUsage(m Mount, sysfsRoot string) (Usage, error) {
+	dev, err := filepath.EvalSymlinks(m.Source)
+	if err != nil {
+		return Usage{}, err
+	}
+	matches, err := filepath.Glob(filepath.Join(sysfsRoot, "fs", "btrfs", "*", "devices", filepath.Base(dev)))
+	if err != nil || len(matches) == 0 {
+		return Usage{}, fmt.Errorf("no btrfs filesystem for %s", m.Source)
+	}
+	fs := filepath.Dir(filepath.Dir(matches[0]))
+
+	var devSize float64
+	devices, _ := filepath.Glob(filepath.Join(fs, "devices", "*"))
+	for _, device := range devices {
+		// The size of block devices is in 512 byte sectors.
+		sectors, err := readUint(filepath.Join(device, "size"))
+		if err != nil {
+			return Usage{}, err
+		}
+		devSize += float64(sectors) * 512
+	}
+	if devSize == 0 {
+		return Usage{}, fmt.Errorf("no btrfs devices for %s", m.Source)
+	}
+	var allocated float64
+	for _, kind := range []string{"data", "metadata", "system"} {
+		diskTotal, err := readUint(filepath.Join(fs, "allocation", kind, "disk_total"))
+		if err != nil {
+			return Usage{}, err
+		}
+		allocated += float64(diskTotal)
+	}
+	dataTotal, err := readUint(filepath.Join(fs, "allocation", "data", "total_bytes"))
+	if err != nil {
+		return Usage{}, err
+	}
+	dataUsed, err := readUint(filepath.Join(fs, "allocation", "data", "bytes_used"))
+	if err != nil {
+		return Usage{}, err
+	}
+	dataDiskTotal, err := readUint(filepath.Join(fs, "allocation", "data", "disk_total"))
+	if err != nil || dataTotal == 0 {
+		return Usage{}, fmt.Errorf("no btrfs data allocation for %s", m.Source)
+	}
+	ratio := float64(dataDiskTotal) / float64(dataTotal)
+	free := float64(dataTotal-dataUsed) + (devSize-allocated)/ratio
+	return Usage{
+		Mount:     m,
+		Total:     unit.Datasize(float64(dataUsed)+free) * unit.Byte,
+		Available: unit.Datasize(free) * unit.Byte,
+	}, nil
+}
+
+// zfsUsage reports the space of the whole pool: statfs reports the size of
+// each dataset as its own usage plus the space available in the pool, which
+// makes every dataset look nearly empty.
+func zfsUsage(m Mount) (Usage, error) {
+	pool, _, _ := strings.Cut(m.Source, "/")
+	out, err := exec.Command("zfs", "list", "-Hp", "-o", "used,available", pool).Output()
+	if err != nil {
+		return Usage{}, err
+	}
+	fields := strings.Fields(string(out))
+	if len(fields) != 2 {
+		return Usage{}, fmt.Errorf("unexpected zfs output %q", out)
+	}
+	used, err := strconv.ParseUint(fields[0], 10, 64)
+	if err != nil {
+		return Usage{}, err
+	}
+	avail, err := strconv.ParseUint(fields[1], 10, 64)
+	if err != nil {
+		return Usage{}, err
+	}
+	return Usage{
+		Mount:     m,
+		Total:     unit.Datasize(used+avail) * unit.Byte,
+		Available: unit.Datasize(avail) * unit.Byte,
+	}, nil
+}
+
+func readUint(path string) (uint64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+}
@@ -0,0 +1,109 @@
+package temperature
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/martinlindhe/unit"
+)
+
+// Sensor is a temperature sensor exposed through hwmon or a thermal zone.
+type Sensor struct {
+	// Name is the hwmon driver name (e.g. "coretemp", "k10temp"), or
+	// "thermal" for thermal zones.
+	Name string
+	// Label is the sensor label (e.g. "Package id 0", "Tctl"), or the type
+	// of a thermal zone (e.g. "x86_pkg_temp").
+	Label string
+	// Path is the file containing the temperature in millidegrees Celsius.
+	Path string
+}
+
+// String returns the sensor as "name/label".
+func (s Sensor) String() string {
+	return s.Name + "/" + s.Label
+}
+
+// Matches reports whether the sensor is identified by the given selector,
+// which is either a label, a name or "name/label" (case-insensitive).
+func (s Sensor) Matches(selector string) bool {
+	return strings.EqualFold(selector, s.Label) ||
+		strings.EqualFold(selector, s.Name) ||
+		strings.EqualFold(selector, s.String())
+}
+
+// Read returns the current temperature of the sensor.
+func (s Sensor) Read() (unit.Temperature, error) {
+	data, err := os.ReadFile(s.Path)
+	if err != nil {
+		return 0, err
+	}
+	milliC, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 0, err
+	}
+	return unit.FromCelsius(float64(milliC) / 1000.0), nil
+}
+
+// Discover returns all temperature sensors found below the given sysfs root,
+// usually "/sys". Hwmon sensors come first, followed by thermal zones.
+func Discover(sysfsRoot string) ([]Sensor, error) {
+	var sensors []Sensor
+	hwmons, err := filepath.Glob(filepath.Join(sysfsRoot, "class", "hwmon", "hwmon*"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(hwmons)
+	for _, hwmon := range hwmons {
+		name := readString(filepath.Join(hwmon, "name"))
+		inputs, _ := filepath.Glob(filepath.Join(hwmon, "temp*_input"))
+		sort.Strings(inputs)
+		for _, input := range inputs {
+			label := readString(strings.TrimSuffix(input, "_input") + "_label")
+			if label == "" {
+				label = strings.TrimSuffix(filepath.Base(input), "_input")
+			}
+			sensors = append(sensors, Sensor{Name: name, Label: label, Path: input})
+		}
+	}
+	zones, err := filepath.Glob(filepath.Join(sysfsRoot, "class", "thermal", "thermal_zone*"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(zones)
+	for _, zone := range zones {
+		sensors = append(sensors, Sensor{
+			Name:  "thermal",
+			Label: readString(filepath.Join(zone, "type")),
+			Path:  filepath.Join(zone, "temp"),
+		})
+	}
+	return sensors, nil
+}
+
+// Select returns the sensors matching the selectors, in the order of the
+// selectors.
+func Select(sensors []Sensor, selectors ...string) []Sensor {
+	var selected []Sensor
+	seen := make(map[string]bool)
+	for _, selector := range selectors {
+		for _, sensor := range sensors {
+			if !seen[sensor.Path] && sensor.Matches(selector) {
+				seen[sensor.Path] = true
+				selected = append(selected, sensor)
+			}
+		}
+	}
+	return selected
+}
+
+func readString(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
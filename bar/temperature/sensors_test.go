@@ -0,0 +1,115 @@
+package temperature
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// fakeSysfs creates a sysfs tree with an intel coretemp hwmon, an unlabelled
+// hwmon and an acpi thermal zone.
+func fakeSysfs(t *testing.T) string {
+	root := t.TempDir()
+	files := map[string]string{
+		"class/hwmon/hwmon0/name":            "coretemp\n",
+		"class/hwmon/hwmon0/temp1_label":     "Package id 0\n",
+		"class/hwmon/hwmon0/temp1_input":     "45000\n",
+		"class/hwmon/hwmon0/temp2_label":     "Core 0\n",
+		"class/hwmon/hwmon0/temp2_input":     "52500\n",
+		"class/hwmon/hwmon1/name":            "nvme\n",
+		"class/hwmon/hwmon1/temp1_input":     "38000\n",
+		"class/thermal/thermal_zone0/type":   "acpitz\n",
+		"class/thermal/thermal_zone0/temp":   "30000\n",
+		"class/thermal/thermal_zone0/policy": "step_wise\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func TestDiscover(t *testing.T) {
+	root := fakeSysfs(t)
+	sensors, err := Discover(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, s := range sensors {
+		got = append(got, s.String())
+	}
+	want := []string{"coretemp/Package id 0", "coretemp/Core 0", "nvme/temp1", "thermal/acpitz"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got sensors %q, want %q", got, want)
+	}
+	temp, err := sensors[1].Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if temp.Celsius() != 52.5 {
+		t.Errorf("got %v°C, want 52.5°C", temp.Celsius())
+	}
+}
+
+func TestSelect(t *testing.T) {
+	sensors, err := Discover(fakeSysfs(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		selectors []string
+		want      []string
+	}{
+		{[]string{"Tctl", "package ID 0"}, []string{"coretemp/Package id 0"}},
+		{[]string{"coretemp"}, []string{"coretemp/Package id 0", "coretemp/Core 0"}},
+		{[]string{"thermal/acpitz", "nvme/temp1"}, []string{"thermal/acpitz", "nvme/temp1"}},
+		{[]string{"Tdie"}, nil},
+	} {
+		var got []string
+		for _, s := range Select(sensors, tc.selectors...) {
+			got = append(got, s.String())
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("Select(%q) = %q, want %q", tc.selectors, got, tc.want)
+		}
+	}
+}
+
+func TestModuleRead(t *testing.T) {
+	root := fakeSysfs(t)
+	for _, tc := range []struct {
+		name  string
+		m     *Module
+		label string
+		temp  float64
+		count int
+	}{
+		{"default", New().SysfsRoot(root), "Package id 0", 45, 1},
+		{"max", New("coretemp", "acpitz").SysfsRoot(root).Max(true), "Core 0", 52.5, 3},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			sensors, err := tc.m.sensors()
+			if err != nil {
+				t.Fatal(err)
+			}
+			info, err := tc.m.read(sensors)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.Label != tc.label || info.Temp.Celsius() != tc.temp || len(info.Readings) != tc.count {
+				t.Errorf("got %s at %v°C of %d readings, want %s at %v°C of %d",
+					info.Label, info.Temp.Celsius(), len(info.Readings), tc.label, tc.temp, tc.count)
+			}
+		})
+	}
+	if _, err := New("Tdie").SysfsRoot(root).sensors(); err == nil {
+		t.Error("no error for a missing sensor")
+	}
+}
@@ -0,0 +1,171 @@
+// Package temperature provides a cpu temperature indicator that discovers
+// hwmon and thermal zone sensors by label.
+package temperature
+
+import (
+	"fmt"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/base/value"
+	"barista.run/colors"
+	"barista.run/outputs"
+	"barista.run/timing"
+
+	"github.com/martinlindhe/unit"
+)
+
+// DefaultSensors are the sensors tried in order if none are configured.
+var DefaultSensors = []string{
+	"Package id 0", // intel coretemp
+	"Tctl",         // amd k10temp
+	"Tdie",
+	"x86_pkg_temp", // thermal zone
+	"cpu_thermal",  // arm
+	"acpitz",
+}
+
+// Reading is the temperature of a single sensor.
+type Reading struct {
+	Sensor
+	Temp unit.Temperature
+}
+
+// Info holds the readings of all selected sensors.
+type Info struct {
+	// Readings holds one reading per selected sensor.
+	Readings []Reading
+	// Reading is the displayed reading, either the first or the hottest
+	// sensor, depending on the configuration.
+	Reading
+	// Degraded and Bad are the configured thresholds.
+	Degraded, Bad unit.Temperature
+}
+
+// Module represents a temperature barista module.
+type Module struct {
+	sysfsRoot  string
+	selectors  []string
+	max        bool
+	degraded   unit.Temperature
+	bad        unit.Temperature
+	scheduler  *timing.Scheduler
+	outputFunc value.Value // of func(Info) bar.Output
+}
+
+// New constructs a temperature module showing the first available sensor
+// matching the selectors (see Sensor.Matches), or one of DefaultSensors if
+// no selector is given.
+func New(selectors ...string) *Module {
+	if len(selectors) == 0 {
+		selectors = DefaultSensors
+	}
+	m := &Module{
+		sysfsRoot: "/sys",
+		selectors: selectors,
+		degraded:  unit.FromCelsius(75),
+		bad:       unit.FromCelsius(90),
+		scheduler: timing.NewScheduler().Every(3 * time.Second),
+	}
+	m.Output(func(i Info) bar.Output {
+		out := outputs.Textf("%.0f℃", i.Temp.Celsius())
+		switch {
+		case i.Temp >= i.Bad:
+			out.Color(colors.Scheme("bad")).Urgent(true)
+		case i.Temp >= i.Degraded:
+			out.Color(colors.Scheme("degraded"))
+		}
+		return out
+	})
+	return m
+}
+
+// Max configures the module to show the hottest of all matching sensors
+// instead of the first one.
+func (m *Module) Max(max bool) *Module {
+	m.max = max
+	return m
+}
+
+// Thresholds sets the temperatures in degrees Celsius above which the output
+// is coloured degraded and bad.
+func (m *Module) Thresholds(degraded, bad float64) *Module {
+	m.degraded = unit.FromCelsius(degraded)
+	m.bad = unit.FromCelsius(bad)
+	return m
+}
+
+// SysfsRoot sets the directory sensors are discovered in, "/sys" by default.
+func (m *Module) SysfsRoot(root string) *Module {
+	m.sysfsRoot = root
+	return m
+}
+
+// RefreshInterval configures the polling frequency.
+func (m *Module) RefreshInterval(interval time.Duration) *Module {
+	m.scheduler.Every(interval)
+	return m
+}
+
+// Output sets the output format for the module.
+func (m *Module) Output(outputFunc func(Info) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+// Stream starts the module.
+func (m *Module) Stream(sink bar.Sink) {
+	sensors, err := m.sensors()
+	if sink.Error(err) {
+		return
+	}
+	info, err := m.read(sensors)
+	outputFunc := m.outputFunc.Get().(func(Info) bar.Output)
+	nextOutputFunc, done := m.outputFunc.Subscribe()
+	defer done()
+	for {
+		if sink.Error(err) {
+			return
+		}
+		sink.Output(outputFunc(info))
+		select {
+		case <-m.scheduler.C:
+			info, err = m.read(sensors)
+		case <-nextOutputFunc:
+			outputFunc = m.outputFunc.Get().(func(Info) bar.Output)
+		}
+	}
+}
+
+// sensors discovers the sensors to read. Unless the maximum is shown, only
+// the first matching sensor is read.
+func (m *Module) sensors() ([]Sensor, error) {
+	all, err := Discover(m.sysfsRoot)
+	if err != nil {
+		return nil, err
+	}
+	sensors := Select(all, m.selectors...)
+	if len(sensors) == 0 {
+		return nil, fmt.Errorf("no temperature sensor matching %q", m.selectors)
+	}
+	if !m.max {
+		sensors = sensors[:1]
+	}
+	return sensors, nil
+}
+
+func (m *Module) read(sensors []Sensor) (Info, error) {
+	info := Info{Degraded: m.degraded, Bad: m.bad}
+	for _, sensor := range sensors {
+		temp, err := sensor.Read()
+		if err != nil {
+			return Info{}, err
+		}
+		reading := Reading{Sensor: sensor, Temp: temp}
+		info.Readings = append(info.Readings, reading)
+		if len(info.Readings) == 1 || temp > info.Temp {
+			info.Reading = reading
+		}
+	}
+	return info, nil
+}
@@ -0,0 +1,87 @@
+package throughput
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/vishvananda/netlink"
+)
+
+// Net constructs a throughput module for a network interface. An interface
+// that does not exist, e.g. an unplugged USB adapter, is shown as down.
+func Net(iface string) *Module {
+	return newModule(func(*Module) (counters, error) {
+		link, err := netlink.LinkByName(iface)
+		var notFound netlink.LinkNotFoundError
+		if errors.As(err, &notFound) {
+			return counters{}, nil
+		}
+		if err != nil {
+			return counters{}, err
+		}
+		attrs := link.Attrs()
+		c := counters{up: attrs.OperState >= netlink.OperDormant}
+		if stats := attrs.Statistics; stats != nil {
+			c.in, c.out = stats.RxBytes, stats.TxBytes
+		}
+		return c, nil
+	})
+}
+
+// Disk constructs a throughput module for a disk, e.g. "nvme0n1". A disk
+// that does not exist is shown as down.
+func Disk(device string) *Module {
+	return newModule(func(m *Module) (counters, error) {
+		return readDiskstats(filepath.Join(m.procRoot, "diskstats"), device)
+	})
+}
+
+// ProcRoot sets the directory disk statistics are read from, "/proc" by
+// default.
+func (m *Module) ProcRoot(root string) *Module {
+	m.procRoot = root
+	return m
+}
+
+// Wireless reports whether a network interface is a wireless one.
+func Wireless(sysfsRoot, iface string) bool {
+	dir := filepath.Join(sysfsRoot, "class", "net", iface)
+	for _, name := range []string{"wireless", "phy80211"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// readDiskstats returns the bytes read and written by a disk. The kernel
+// counts in sectors of 512 bytes regardless of the device's block size.
+func readDiskstats(path, device string) (counters, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return counters{}, err
+	}
+	defer f.Close()
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		// See Documentation/admin-guide/iostats.rst.
+		fields := strings.Fields(s.Text())
+		if len(fields) < 10 || fields[2] != device {
+			continue
+		}
+		read, err := strconv.ParseUint(fields[5], 10, 64)
+		if err != nil {
+			return counters{}, err
+		}
+		written, err := strconv.ParseUint(fields[9], 10, 64)
+		if err != nil {
+			return counters{}, err
+		}
+		return counters{in: read * 512, out: written * 512, up: true}, nil
+	}
+	return counters{}, s.Err()
+}
@@ -0,0 +1,131 @@
+// Package throughput provides network and disk throughput indicators with a
+// sparkline history of the total rate. Samples are recorded when the
+// counters are read, so rendering the output again does not add to the
+// history.
+package throughput
+
+import (
+	"time"
+
+	"barista.run/bar"
+	"barista.run/base/value"
+	"barista.run/outputs"
+	"barista.run/timing"
+
+	"github.com/martinlindhe/unit"
+	"github.com/tionis/i3-tools/bar/sparkline"
+)
+
+// Info is the throughput since the previous sample.
+type Info struct {
+	// In and Out are the received and sent, or read and written, rates.
+	In, Out unit.Datarate
+	// Up reports whether the interface is connected, or the disk present.
+	Up bool
+	// History holds the total rates of the recent samples.
+	History *sparkline.History
+}
+
+// Total returns the sum of both directions.
+func (i Info) Total() unit.Datarate {
+	return i.In + i.Out
+}
+
+// counters are the cumulative bytes transferred in both directions.
+type counters struct {
+	in, out uint64
+	up      bool
+}
+
+// Module represents a throughput barista module.
+type Module struct {
+	read       func(*Module) (counters, error)
+	procRoot   string
+	size       int
+	scheduler  *timing.Scheduler
+	outputFunc value.Value // of func(Info) bar.Output
+}
+
+func newModule(read func(*Module) (counters, error)) *Module {
+	m := &Module{
+		read:      read,
+		procRoot:  "/proc",
+		size:      8,
+		scheduler: timing.NewScheduler().Every(3 * time.Second),
+	}
+	m.Output(func(i Info) bar.Output {
+		if !i.Up {
+			return nil
+		}
+		return outputs.Pango(i.History.Sparkline(), " ↓", sparkline.Rate(i.In), " ↑", sparkline.Rate(i.Out))
+	})
+	return m
+}
+
+// HistorySize sets the number of samples kept in the history, 8 by default.
+func (m *Module) HistorySize(size int) *Module {
+	m.size = size
+	return m
+}
+
+// RefreshInterval configures the polling frequency. The rates are averaged
+// over this interval.
+func (m *Module) RefreshInterval(interval time.Duration) *Module {
+	m.scheduler.Every(interval)
+	return m
+}
+
+// Output sets the output format for the module.
+func (m *Module) Output(outputFunc func(Info) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+// Stream starts the module. Nothing is shown until the first rates are
+// known after one refresh interval.
+func (m *Module) Stream(sink bar.Sink) {
+	last, err := m.read(m)
+	if sink.Error(err) {
+		return
+	}
+	lastRead := timing.Now()
+	history := sparkline.New(m.size)
+	var info Info
+	sampled := false
+	outputFunc := m.outputFunc.Get().(func(Info) bar.Output)
+	nextOutputFunc, done := m.outputFunc.Subscribe()
+	defer done()
+	for {
+		if sampled {
+			sink.Output(outputFunc(info))
+		}
+		select {
+		case <-m.scheduler.C:
+			cur, err := m.read(m)
+			if sink.Error(err) {
+				return
+			}
+			now := timing.Now()
+			seconds := now.Sub(lastRead).Seconds()
+			info = Info{
+				In:      rate(last.in, cur.in, seconds),
+				Out:     rate(last.out, cur.out, seconds),
+				Up:      cur.up,
+				History: history,
+			}
+			history.Add(float64(info.Total()))
+			last, lastRead, sampled = cur, now, true
+		case <-nextOutputFunc:
+			outputFunc = m.outputFunc.Get().(func(Info) bar.Output)
+		}
+	}
+}
+
+// rate returns the rate between two readings of a counter. Counters reset
+// when an interface or disk reappears, which is not a negative rate.
+func rate(last, cur uint64, seconds float64) unit.Datarate {
+	if cur < last || seconds <= 0 {
+		return 0
+	}
+	return unit.Datarate(float64(cur-last)/seconds) * unit.BytePerSecond
+}
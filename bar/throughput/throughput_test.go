@@ -0,0 +1,100 @@
+package throughput
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/timing"
+
+	"github.com/martinlindhe/unit"
+)
+
+func writeDiskstats(t *testing.T, dir string, read, written int) {
+	data := fmt.Sprintf(
+		" 259       0 nvme0n1 100 0 %d 50 200 0 %d 80 0 120 130 0 0 0 0\n"+
+			" 259       1 nvme0n1p1 10 0 20 5 0 0 0 0 0 5 5 0 0 0 0\n", read, written)
+	if err := os.WriteFile(filepath.Join(dir, "diskstats"), []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReadDiskstats(t *testing.T) {
+	dir := t.TempDir()
+	writeDiskstats(t, dir, 4, 2)
+	path := filepath.Join(dir, "diskstats")
+	c, err := readDiskstats(path, "nvme0n1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (counters{in: 2048, out: 1024, up: true}); c != want {
+		t.Errorf("got %+v, want %+v", c, want)
+	}
+	if c, err := readDiskstats(path, "sda"); err != nil || c.up {
+		t.Errorf("got %+v, %v for a missing disk, want it down", c, err)
+	}
+}
+
+func TestWireless(t *testing.T) {
+	root := t.TempDir()
+	for _, dir := range []string{"class/net/wlan0/wireless", "class/net/wlp3s0/phy80211", "class/net/wg0", "class/net/eth0"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for iface, want := range map[string]bool{"wlan0": true, "wlp3s0": true, "wg0": false, "eth0": false, "missing": false} {
+		if got := Wireless(root, iface); got != want {
+			t.Errorf("Wireless(%s) = %v, want %v", iface, got, want)
+		}
+	}
+}
+
+func TestHistoryOnlyGrowsWithSamples(t *testing.T) {
+	timing.TestMode()
+	defer timing.ExitTestMode()
+	dir := t.TempDir()
+	writeDiskstats(t, dir, 0, 0)
+
+	type output struct {
+		tag     string
+		info    Info
+		samples int
+	}
+	outs := make(chan output, 10)
+	outputFunc := func(tag string) func(Info) bar.Output {
+		return func(i Info) bar.Output {
+			outs <- output{tag, i, i.History.Len()}
+			return nil
+		}
+	}
+	m := Disk("nvme0n1").ProcRoot(dir).Output(outputFunc("first"))
+	go m.Stream(bar.Sink(func(bar.Output) {}))
+	next := func() output {
+		t.Helper()
+		select {
+		case o := <-outs:
+			return o
+		case <-time.After(5 * time.Second):
+			t.Fatal("no output")
+			return output{}
+		}
+	}
+
+	timing.NextTick()
+	if o := next(); o.tag != "first" || o.samples != 1 {
+		t.Fatalf("got %+v after the first refresh, want one sample", o)
+	}
+	m.Output(outputFunc("second"))
+	if o := next(); o.tag != "second" || o.samples != 1 {
+		t.Fatalf("got %+v after changing the output, want still one sample", o)
+	}
+	writeDiskstats(t, dir, 6000, 3000)
+	timing.NextTick()
+	o := next()
+	if o.samples != 2 || o.info.In != 1024000*unit.BytePerSecond || o.info.Out != 512000*unit.BytePerSecond {
+		t.Errorf("got %+v after the second refresh, want two samples at 1024000 and 512000 B/s", o)
+	}
+}
@@ -0,0 +1,135 @@
+package topproc
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/martinlindhe/unit"
+)
+
+// userHZ is the unit of the cpu times in /proc/[pid]/stat. It is fixed to 100
+// on all architectures the kernel exposes to userspace.
+const userHZ = 100
+
+// Process is a running process and its resource usage.
+type Process struct {
+	PID  int
+	Name string
+	// CPU is the share of a single core used since the previous scan, so
+	// a multithreaded process can exceed 1.
+	CPU float64
+	// RSS is the resident memory of the process.
+	RSS unit.Datasize
+	// Mem is the share of total memory resident for the process.
+	Mem float64
+}
+
+// sample is the raw usage of a process as read from procfs.
+type sample struct {
+	pid      int
+	name     string
+	ticks    uint64
+	resident uint64
+}
+
+// parseStat returns the command name and the user and system cpu time in
+// ticks from the contents of /proc/[pid]/stat.
+func parseStat(data []byte) (name string, ticks uint64, err error) {
+	// The name may contain spaces and parentheses, so it ends at the last
+	// closing parenthesis.
+	open := bytes.IndexByte(data, '(')
+	end := bytes.LastIndexByte(data, ')')
+	if open < 0 || end < open {
+		return "", 0, fmt.Errorf("malformed stat")
+	}
+	name = string(data[open+1 : end])
+	// Fields after the name start with the state (field 3), utime and
+	// stime are fields 14 and 15.
+	fields := strings.Fields(string(data[end+1:]))
+	if len(fields) < 13 {
+		return "", 0, fmt.Errorf("malformed stat")
+	}
+	utime, err := strconv.ParseUint(fields[11], 10, 64)
+	if err != nil {
+		return "", 0, err
+	}
+	stime, err := strconv.ParseUint(fields[12], 10, 64)
+	if err != nil {
+		return "", 0, err
+	}
+	return name, utime + stime, nil
+}
+
+// parseStatm returns the resident set size in pages from the contents of
+// /proc/[pid]/statm.
+func parseStatm(data []byte) (uint64, error) {
+	fields := strings.Fields(string(data))
+	if len(fields) < 2 {
+		return 0, fmt.Errorf("malformed statm")
+	}
+	return strconv.ParseUint(fields[1], 10, 64)
+}
+
+// scan reads the usage of all processes. Processes exiting during the scan
+// and kernel threads without memory are skipped.
+func scan(procRoot string) ([]sample, error) {
+	entries, err := os.ReadDir(procRoot)
+	if err != nil {
+		return nil, err
+	}
+	var samples []sample
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		dir := filepath.Join(procRoot, entry.Name())
+		stat, err := os.ReadFile(filepath.Join(dir, "stat"))
+		if err != nil {
+			continue
+		}
+		name, ticks, err := parseStat(stat)
+		if err != nil {
+			continue
+		}
+		statm, err := os.ReadFile(filepath.Join(dir, "statm"))
+		if err != nil {
+			continue
+		}
+		resident, err := parseStatm(statm)
+		if err != nil || resident == 0 {
+			continue
+		}
+		samples = append(samples, sample{pid: pid, name: name, ticks: ticks, resident: resident})
+	}
+	return samples, nil
+}
+
+// memTotal returns the total memory from /proc/meminfo.
+func memTotal(procRoot string) (unit.Datasize, error) {
+	f, err := os.Open(filepath.Join(procRoot, "meminfo"))
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) >= 2 && fields[0] == "MemTotal:" {
+			kb, err := strconv.ParseUint(fields[1], 10, 64)
+			if err != nil {
+				return 0, err
+			}
+			return unit.Datasize(kb) * unit.Kibibyte, nil
+		}
+	}
+	if err := s.Err(); err != nil {
+		return 0, err
+	}
+	return 0, fmt.Errorf("no MemTotal in meminfo")
+}
@@ -0,0 +1,88 @@
+package topproc
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/martinlindhe/unit"
+)
+
+const procRoot = "testdata/proc"
+
+func TestParseStat(t *testing.T) {
+	for _, tc := range []struct {
+		pid   string
+		name  string
+		ticks uint64
+	}{
+		{"1", "systemd", 3500},
+		{"731", "Web Content", 6000},
+		{"4242", "a) b (c", 10},
+	} {
+		data, err := os.ReadFile(filepath.Join(procRoot, tc.pid, "stat"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		name, ticks, err := parseStat(data)
+		if err != nil {
+			t.Fatalf("parseStat(%s): %v", tc.pid, err)
+		}
+		if name != tc.name || ticks != tc.ticks {
+			t.Errorf("got %q with %d ticks, want %q with %d", name, ticks, tc.name, tc.ticks)
+		}
+	}
+	for _, data := range []string{"", "1 systemd S 0", "1 (systemd) S 0 1", "1 (x) S 0 1 1 0 -1 0 0 0 0 0 a b"} {
+		if _, _, err := parseStat([]byte(data)); err == nil {
+			t.Errorf("no error for %q", data)
+		}
+	}
+}
+
+func TestScan(t *testing.T) {
+	samples, err := scan(procRoot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var pids []int
+	for _, s := range samples {
+		pids = append(pids, s.pid)
+	}
+	sort.Ints(pids)
+	// The kernel thread without memory and self are skipped.
+	if len(pids) != 3 || pids[0] != 1 || pids[1] != 731 || pids[2] != 4242 {
+		t.Errorf("got pids %v, want 1, 731 and 4242", pids)
+	}
+}
+
+func TestRead(t *testing.T) {
+	total, err := memTotal(procRoot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 16000000*unit.Kibibyte {
+		t.Fatalf("got total memory %v, want 16000000 KiB", total)
+	}
+	prev := map[int]uint64{1: 3400, 731: 5000, 4242: 10}
+	info, err := New().ProcRoot(procRoot).read(total, prev, 10*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rss := 500000 * unit.Datasize(os.Getpagesize()) * unit.Byte
+	near := func(a, b float64) bool { return math.Abs(a-b) < 1e-9 }
+	if info.CPU.PID != 731 || !near(info.CPU.CPU, 1) {
+		t.Errorf("got top cpu %+v, want Web Content at 100%%", info.CPU)
+	}
+	if info.Mem.PID != 731 || info.Mem.RSS != rss || !near(info.Mem.Mem, float64(rss/total)) {
+		t.Errorf("got top memory %+v, want Web Content with %v", info.Mem, rss)
+	}
+	if !info.CPUHog() || info.MemHog() != (info.Mem.Mem >= 0.25) {
+		t.Errorf("got cpu hog %v and memory hog %v", info.CPUHog(), info.MemHog())
+	}
+	if len(prev) != 3 || prev[731] != 6000 {
+		t.Errorf("got previous ticks %v, want those of this scan", prev)
+	}
+}
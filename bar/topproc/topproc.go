@@ -0,0 +1,191 @@
+// Package topproc provides an indicator for the processes using the most cpu
+// and memory, to explain why the load or memory segments turned bad.
+package topproc
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/base/value"
+	"barista.run/colors"
+	"barista.run/format"
+	"barista.run/outputs"
+	"barista.run/timing"
+
+	"github.com/martinlindhe/unit"
+)
+
+// Info holds the top cpu and memory consumers.
+type Info struct {
+	// CPU is the process that used the most cpu since the previous scan.
+	CPU Process
+	// Mem is the process with the largest resident memory.
+	Mem Process
+	// CPUThreshold and MemThreshold are the configured thresholds.
+	CPUThreshold, MemThreshold float64
+}
+
+// CPUHog reports whether the top cpu consumer exceeds the threshold.
+func (i Info) CPUHog() bool {
+	return i.CPU.PID != 0 && i.CPU.CPU >= i.CPUThreshold
+}
+
+// MemHog reports whether the top memory consumer exceeds the threshold.
+func (i Info) MemHog() bool {
+	return i.Mem.PID != 0 && i.Mem.Mem >= i.MemThreshold
+}
+
+// Module represents a top process barista module.
+type Module struct {
+	procRoot     string
+	cpuThreshold float64
+	memThreshold float64
+	scheduler    *timing.Scheduler
+	outputFunc   value.Value // of func(Info) bar.Output
+}
+
+// New constructs a top process module. By default it only shows a process
+// using at least 90% of a core or 25% of the memory, and offers to kill or
+// renice it on right click.
+func New() *Module {
+	m := &Module{
+		procRoot:     "/proc",
+		cpuThreshold: 0.9,
+		memThreshold: 0.25,
+		scheduler:    timing.NewScheduler().Every(5 * time.Second),
+	}
+	m.Output(func(i Info) bar.Output {
+		var p Process
+		var out *bar.Segment
+		switch {
+		case i.CPUHog():
+			p = i.CPU
+			out = outputs.Textf("%s %.0f%%", p.Name, p.CPU*100)
+		case i.MemHog():
+			p = i.Mem
+			out = outputs.Pango(p.Name, " ", format.IBytesize(p.RSS))
+		default:
+			return nil
+		}
+		return out.Color(colors.Scheme("bad")).OnClick(func(e bar.Event) {
+			if e.Button == bar.ButtonRight {
+				_ = Confirm(p)
+			}
+		})
+	})
+	return m
+}
+
+// Thresholds sets the share of a single core and the share of total memory
+// above which a process is considered a hog.
+func (m *Module) Thresholds(cpu, mem float64) *Module {
+	m.cpuThreshold = cpu
+	m.memThreshold = mem
+	return m
+}
+
+// ProcRoot sets the directory processes are read from, "/proc" by default.
+func (m *Module) ProcRoot(root string) *Module {
+	m.procRoot = root
+	return m
+}
+
+// RefreshInterval configures the polling frequency.
+func (m *Module) RefreshInterval(interval time.Duration) *Module {
+	m.scheduler.Every(interval)
+	return m
+}
+
+// Output sets the output format for the module.
+func (m *Module) Output(outputFunc func(Info) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+// Stream starts the module.
+func (m *Module) Stream(sink bar.Sink) {
+	total, err := memTotal(m.procRoot)
+	if sink.Error(err) {
+		return
+	}
+	prev := map[int]uint64{}
+	lastScan := time.Now()
+	info, err := m.read(total, prev, 0)
+	outputFunc := m.outputFunc.Get().(func(Info) bar.Output)
+	nextOutputFunc, done := m.outputFunc.Subscribe()
+	defer done()
+	for {
+		if sink.Error(err) {
+			return
+		}
+		sink.Output(outputFunc(info))
+		select {
+		case <-m.scheduler.C:
+			now := time.Now()
+			info, err = m.read(total, prev, now.Sub(lastScan))
+			lastScan = now
+		case <-nextOutputFunc:
+			outputFunc = m.outputFunc.Get().(func(Info) bar.Output)
+		}
+	}
+}
+
+// read scans all processes and returns the top consumers. The cpu usage is
+// computed from the ticks of the previous scan, which are replaced in prev.
+func (m *Module) read(total unit.Datasize, prev map[int]uint64, elapsed time.Duration) (Info, error) {
+	samples, err := scan(m.procRoot)
+	if err != nil {
+		return Info{}, err
+	}
+	pageSize := unit.Datasize(os.Getpagesize()) * unit.Byte
+	cpu := func(s sample) float64 {
+		last, ok := prev[s.pid]
+		if !ok || s.ticks < last || elapsed <= 0 {
+			return 0
+		}
+		return float64(s.ticks-last) / userHZ / elapsed.Seconds()
+	}
+	process := func(s sample) Process {
+		rss := unit.Datasize(s.resident) * pageSize
+		return Process{PID: s.pid, Name: s.name, CPU: cpu(s), RSS: rss, Mem: float64(rss / total)}
+	}
+
+	info := Info{CPUThreshold: m.cpuThreshold, MemThreshold: m.memThreshold}
+	for _, s := range samples {
+		if c := cpu(s); c > info.CPU.CPU {
+			info.CPU = process(s)
+		}
+		if info.Mem.PID == 0 || unit.Datasize(s.resident)*pageSize > info.Mem.RSS {
+			info.Mem = process(s)
+		}
+	}
+	for pid := range prev {
+		delete(prev, pid)
+	}
+	for _, s := range samples {
+		prev[s.pid] = s.ticks
+	}
+	return info, nil
+}
+
+// Confirm asks for confirmation through i3-nagbar before killing or
+// renicing the process. The actions run directly rather than in a terminal. The actions check that the pid still belongs to the
+// same command, since the process may have exited in the meantime.
+func Confirm(p Process) error {
+	check := fmt.Sprintf("[ \"$(cat /proc/%d/comm)\" = %s ]", p.PID, shellQuote(p.Name))
+	return exec.Command("i3-nagbar", "-t", "warning",
+		"-m", fmt.Sprintf("%s (pid %d) is using %.0f%% cpu and %s memory.", p.Name, p.PID, p.CPU*100, format.IBytesize(p.RSS)),
+		"-B", "Terminate", fmt.Sprintf("%s && kill %d", check, p.PID),
+		"-B", "Kill", fmt.Sprintf("%s && kill -9 %d", check, p.PID),
+		"-B", "Renice", fmt.Sprintf("%s && renice -n 10 -p %d", check, p.PID),
+	).Run()
+}
+
+// shellQuote quotes s for use as a single word in sh.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,133 @@
+package vpn
+
+import (
+	"errors"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const (
+	nmService     = "org.freedesktop.NetworkManager"
+	nmPath        = "/org/freedesktop/NetworkManager"
+	nmActiveIface = nmService + ".Connection.Active"
+	nmDeviceIface = nmService + ".Device"
+)
+
+// ConnState is the state of a NetworkManager connection.
+type ConnState uint32
+
+// States of NM_ACTIVE_CONNECTION_STATE.
+const (
+	Unknown ConnState = iota
+	Activating
+	Activated
+	Deactivating
+	Deactivated
+)
+
+// Connection is a NetworkManager VPN or WireGuard connection.
+type Connection struct {
+	// ID is the name of the connection, as used by nmcli.
+	ID   string
+	Type string
+	// State is Deactivated for configured connections that are not active.
+	State ConnState
+	// WireGuard is the interface of an active WireGuard connection.
+	WireGuard *Interface
+}
+
+// Active reports whether the connection is up.
+func (c Connection) Active() bool {
+	return c.State == Activated
+}
+
+// nmConnections returns the active VPN and WireGuard connections and the
+// configured connections, which are listed even if they are not active. If
+// NetworkManager is not running, only the configured ones are listed.
+func nmConnections(conn *dbus.Conn, configured []string, wireguard []Interface) ([]Connection, error) {
+	var connections []Connection
+	seen := map[string]bool{}
+	paths, err := activeConnections(conn)
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range paths {
+		obj := conn.Object(nmService, path)
+		var c Connection
+		var state uint32
+		var devices []dbus.ObjectPath
+		var isVPN bool
+		if err := getProperties(obj, nmActiveIface, map[string]interface{}{
+			"Id":      &c.ID,
+			"Type":    &c.Type,
+			"State":   &state,
+			"Vpn":     &isVPN,
+			"Devices": &devices,
+		}); err != nil {
+			// The connection went away in the meantime.
+			continue
+		}
+		c.State = ConnState(state)
+		if !isVPN && c.Type != "wireguard" && !contains(configured, c.ID) {
+			continue
+		}
+		if c.Type == "wireguard" && len(devices) > 0 {
+			var name string
+			if err := getProperties(conn.Object(nmService, devices[0]), nmDeviceIface,
+				map[string]interface{}{"Interface": &name}); err == nil {
+				for n := range wireguard {
+					if wireguard[n].Name == name {
+						c.WireGuard = &wireguard[n]
+					}
+				}
+			}
+		}
+		seen[c.ID] = true
+		connections = append(connections, c)
+	}
+	for _, id := range configured {
+		if !seen[id] {
+			connections = append(connections, Connection{ID: id, State: Deactivated})
+		}
+	}
+	return connections, nil
+}
+
+// activeConnections returns the object paths of the active connections, or
+// none if NetworkManager is not running.
+func activeConnections(conn *dbus.Conn) ([]dbus.ObjectPath, error) {
+	if conn == nil {
+		return nil, nil
+	}
+	var paths []dbus.ObjectPath
+	err := getProperties(conn.Object(nmService, nmPath), nmService,
+		map[string]interface{}{"ActiveConnections": &paths})
+	var dbusErr dbus.Error
+	if errors.As(err, &dbusErr) && dbusErr.Name == "org.freedesktop.DBus.Error.ServiceUnknown" {
+		return nil, nil
+	}
+	return paths, err
+}
+
+// getProperties stores the given properties of an interface.
+func getProperties(obj dbus.BusObject, iface string, props map[string]interface{}) error {
+	for name, ptr := range props {
+		v, err := obj.GetProperty(iface + "." + name)
+		if err != nil {
+			return err
+		}
+		if err := v.Store(ptr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,135 @@
+package vpn
+
+import (
+	"bufio"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const propertiesIface = "org.freedesktop.DBus.Properties"
+
+// startBus starts a private session bus and returns its address.
+func startBus(t *testing.T) string {
+	if _, err := exec.LookPath("dbus-daemon"); err != nil {
+		t.Skip("dbus-daemon not found")
+	}
+	cmd := exec.Command("dbus-daemon", "--session", "--nofork", "--print-address",
+		"--address=unix:path="+filepath.Join(t.TempDir(), "bus"))
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	})
+	address, err := bufio.NewReader(stdout).ReadString('\n')
+	if err != nil {
+		t.Fatalf("failed to read bus address: %v", err)
+	}
+	return strings.TrimSpace(address)
+}
+
+func connect(t *testing.T, address string) *dbus.Conn {
+	conn, err := dbus.Connect(address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// fakeNetworkManager exports the properties of objects by path and
+// interface.
+type fakeNetworkManager map[dbus.ObjectPath]map[string]map[string]interface{}
+
+func (f fakeNetworkManager) serve(t *testing.T, address string) {
+	conn := connect(t, address)
+	for path, ifaces := range f {
+		ifaces := ifaces
+		get := func(iface, name string) (dbus.Variant, *dbus.Error) {
+			v, ok := ifaces[iface][name]
+			if !ok {
+				return dbus.Variant{}, dbus.MakeFailedError(dbus.ErrMsgUnknownMethod)
+			}
+			return dbus.MakeVariant(v), nil
+		}
+		if err := conn.ExportMethodTable(map[string]interface{}{"Get": get}, path, propertiesIface); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := conn.RequestName(nmService, dbus.NameFlagDoNotQueue); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func active(id, typ string, state ConnState, vpn bool, devices ...dbus.ObjectPath) map[string]map[string]interface{} {
+	if devices == nil {
+		devices = []dbus.ObjectPath{}
+	}
+	return map[string]map[string]interface{}{nmActiveIface: {
+		"Id":      id,
+		"Type":    typ,
+		"State":   uint32(state),
+		"Vpn":     vpn,
+		"Devices": devices,
+	}}
+}
+
+func TestNMConnections(t *testing.T) {
+	address := startBus(t)
+	fakeNetworkManager{
+		nmPath: {nmService: {"ActiveConnections": []dbus.ObjectPath{
+			"/active/1", "/active/2", "/active/3", "/active/4",
+		}}},
+		"/active/1": active("office", "vpn", Activating, true),
+		"/active/2": active("home", "wireguard", Activated, false, "/devices/7"),
+		"/active/3": active("Wired", "802-3-ethernet", Activated, false, "/devices/2"),
+		// Gone between listing and reading it.
+		"/active/4":  {},
+		"/devices/7": {nmDeviceIface: {"Interface": "wg0"}},
+	}.serve(t, address)
+
+	wireguard := []Interface{{Name: "wg1"}, {Name: "wg0", Up: true}}
+	got, err := nmConnections(connect(t, address), []string{"home", "backup"}, wireguard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Connection{
+		{ID: "office", Type: "vpn", State: Activating},
+		{ID: "home", Type: "wireguard", State: Activated, WireGuard: &wireguard[1]},
+		{ID: "backup", State: Deactivated},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+	if got[1].WireGuard != &wireguard[1] {
+		t.Error("wireguard connection does not point to its interface")
+	}
+}
+
+func TestNMConnectionsWithoutNetworkManager(t *testing.T) {
+	want := []Connection{{ID: "backup", State: Deactivated}}
+	got, err := nmConnections(connect(t, startBus(t)), []string{"backup"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v without NetworkManager, want %+v", got, want)
+	}
+	got, err = nmConnections(nil, []string{"backup"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v without a bus, want %+v", got, want)
+	}
+}
@@ -0,0 +1,195 @@
+// Package vpn provides a VPN indicator covering WireGuard interfaces, read
+// through netlink, and NetworkManager VPN and WireGuard connections, which
+// can be toggled.
+package vpn
+
+import (
+	"fmt"
+	"log"
+	"os/exec"
+	"strings"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/base/value"
+	"barista.run/format"
+	"barista.run/outputs"
+	"barista.run/timing"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// Info is the state of all VPNs.
+type Info struct {
+	// WireGuard holds the WireGuard interfaces that are not managed by
+	// NetworkManager, sorted by name.
+	WireGuard []Interface
+	// Connections holds the active NetworkManager VPN and WireGuard
+	// connections followed by the configured connections that are not
+	// active.
+	Connections []Connection
+
+	m *Module
+}
+
+// Active reports whether any VPN is up.
+func (i Info) Active() bool {
+	for _, iface := range i.WireGuard {
+		if iface.Up {
+			return true
+		}
+	}
+	for _, c := range i.Connections {
+		if c.Active() {
+			return true
+		}
+	}
+	return false
+}
+
+// Toggle brings a NetworkManager connection up or down.
+func (i Info) Toggle(c Connection) {
+	if i.m == nil {
+		return
+	}
+	action := "up"
+	if c.Active() || c.State == Activating {
+		action = "down"
+	}
+	go func() {
+		out, err := exec.Command("nmcli", "connection", action, "id", c.ID).CombinedOutput()
+		if err != nil {
+			log.Printf("failed to bring %s %s: %v: %s", c.ID, action, err, strings.TrimSpace(string(out)))
+		}
+		i.m.refresh.Set(struct{}{})
+	}()
+}
+
+// Module represents a VPN barista module.
+type Module struct {
+	connections []string
+	scheduler   *timing.Scheduler
+	outputFunc  value.Value // of func(Info) bar.Output
+	refresh     value.Value // of struct{}
+}
+
+// New constructs a VPN module.
+func New() *Module {
+	m := &Module{scheduler: timing.NewScheduler().Every(5 * time.Second)}
+	m.Output(func(i Info) bar.Output {
+		out := outputs.Group()
+		for _, iface := range i.WireGuard {
+			out.Append(outputs.Text(iface.Name + wireguardStatus(iface)))
+		}
+		for _, c := range i.Connections {
+			c := c
+			text := c.ID
+			if c.WireGuard != nil {
+				text += wireguardStatus(*c.WireGuard)
+			}
+			if !c.Active() {
+				text += " (off)"
+			}
+			out.Append(outputs.Text(text).OnClick(func(e bar.Event) {
+				if e.Button == bar.ButtonLeft {
+					i.Toggle(c)
+				}
+			}))
+		}
+		if len(out.Segments()) == 0 {
+			return nil
+		}
+		return out
+	})
+	return m
+}
+
+// wireguardStatus formats the age of the last handshake and the transfer of
+// an interface, if known.
+func wireguardStatus(iface Interface) string {
+	if !iface.PeersKnown {
+		return ""
+	}
+	received, sent := iface.Transfer()
+	status := fmt.Sprintf(" ↓%s ↑%s", format.IBytesize(received), format.IBytesize(sent))
+	if last := iface.LastHandshake(); !last.IsZero() {
+		status = fmt.Sprintf(" %s ago", time.Since(last).Truncate(time.Second)) + status
+	}
+	return status
+}
+
+// Connections lists NetworkManager connections by name that should be shown
+// and can be toggled even while they are not active.
+func (m *Module) Connections(ids ...string) *Module {
+	m.connections = ids
+	return m
+}
+
+// RefreshInterval configures the polling frequency.
+func (m *Module) RefreshInterval(interval time.Duration) *Module {
+	m.scheduler.Every(interval)
+	return m
+}
+
+// Output sets the output format for the module.
+func (m *Module) Output(outputFunc func(Info) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+// Stream starts the module.
+func (m *Module) Stream(sink bar.Sink) {
+	// Without the system bus only WireGuard interfaces are shown.
+	conn, err := dbus.ConnectSystemBus()
+	if err != nil {
+		log.Printf("failed to connect to system bus: %v", err)
+		conn = nil
+	} else {
+		defer conn.Close()
+	}
+
+	info, err := m.read(conn)
+	outputFunc := m.outputFunc.Get().(func(Info) bar.Output)
+	nextOutputFunc, done := m.outputFunc.Subscribe()
+	defer done()
+	nextRefresh, done := m.refresh.Subscribe()
+	defer done()
+	for {
+		if sink.Error(err) {
+			return
+		}
+		sink.Output(outputFunc(info))
+		select {
+		case <-m.scheduler.C:
+			info, err = m.read(conn)
+		case <-nextRefresh:
+			info, err = m.read(conn)
+		case <-nextOutputFunc:
+			outputFunc = m.outputFunc.Get().(func(Info) bar.Output)
+		}
+	}
+}
+
+func (m *Module) read(conn *dbus.Conn) (Info, error) {
+	wireguard, err := wireguardInterfaces()
+	if err != nil {
+		return Info{}, err
+	}
+	connections, err := nmConnections(conn, m.connections, wireguard)
+	if err != nil {
+		return Info{}, err
+	}
+	managed := map[string]bool{}
+	for _, c := range connections {
+		if c.WireGuard != nil {
+			managed[c.WireGuard.Name] = true
+		}
+	}
+	info := Info{Connections: connections, m: m}
+	for _, iface := range wireguard {
+		if !managed[iface.Name] {
+			info.WireGuard = append(info.WireGuard, iface)
+		}
+	}
+	return info, nil
+}
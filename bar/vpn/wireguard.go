@@ -0,0 +1,195 @@
+package vpn
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"net"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/martinlindhe/unit"
+	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netlink/nl"
+	"golang.org/x/sys/unix"
+)
+
+// Generic netlink commands and attributes from linux/wireguard.h.
+const (
+	wgGenlName      = "wireguard"
+	wgGenlVersion   = 1
+	wgCmdGetDevice  = 0
+	wgDeviceIfname  = 2
+	wgDevicePeers   = 8
+	wgPeerPublicKey = 1
+	wgPeerEndpoint  = 4
+	wgPeerHandshake = 6
+	wgPeerRxBytes   = 7
+	wgPeerTxBytes   = 8
+)
+
+// attrFlags are the flag bits of a netlink attribute type.
+const attrFlags = unix.NLA_F_NESTED | unix.NLA_F_NET_BYTEORDER
+
+// Peer is a WireGuard peer.
+type Peer struct {
+	// PublicKey is base64 encoded like in wg(8).
+	PublicKey string
+	Endpoint  string
+	// LastHandshake is zero if there was no handshake yet.
+	LastHandshake time.Time
+	Received      unit.Datasize
+	Sent          unit.Datasize
+}
+
+// Interface is a WireGuard interface.
+type Interface struct {
+	Name string
+	Up   bool
+	// Peers is only known if they could be read, which requires
+	// CAP_NET_ADMIN.
+	Peers      []Peer
+	PeersKnown bool
+}
+
+// LastHandshake returns the most recent handshake with any peer.
+func (i Interface) LastHandshake() time.Time {
+	var last time.Time
+	for _, p := range i.Peers {
+		if p.LastHandshake.After(last) {
+			last = p.LastHandshake
+		}
+	}
+	return last
+}
+
+// Transfer returns the data received from and sent to all peers.
+func (i Interface) Transfer() (received, sent unit.Datasize) {
+	for _, p := range i.Peers {
+		received += p.Received
+		sent += p.Sent
+	}
+	return received, sent
+}
+
+// wireguardInterfaces returns all WireGuard interfaces sorted by name.
+func wireguardInterfaces() ([]Interface, error) {
+	links, err := netlink.LinkList()
+	if err != nil {
+		return nil, err
+	}
+	var ifaces []Interface
+	var family *netlink.GenlFamily
+	for _, link := range links {
+		if link.Type() != wgGenlName {
+			continue
+		}
+		attrs := link.Attrs()
+		iface := Interface{Name: attrs.Name, Up: attrs.Flags&net.FlagUp != 0}
+		if family == nil {
+			family, err = netlink.GenlFamilyGet(wgGenlName)
+		}
+		// Peers stay unknown without CAP_NET_ADMIN, or if an interface
+		// went away or cannot be read otherwise, which does not affect
+		// the other interfaces.
+		if family != nil {
+			iface.Peers, err = readPeers(family, attrs.Name)
+			iface.PeersKnown = err == nil
+		}
+		ifaces = append(ifaces, iface)
+	}
+	sort.Slice(ifaces, func(i, j int) bool {
+		return ifaces[i].Name < ifaces[j].Name
+	})
+	return ifaces, nil
+}
+
+// readPeers dumps the peers of an interface. Interfaces with many peers are
+// split into several messages.
+func readPeers(family *netlink.GenlFamily, name string) ([]Peer, error) {
+	req := nl.NewNetlinkRequest(int(family.ID), unix.NLM_F_DUMP)
+	req.AddData(&nl.Genlmsg{Command: wgCmdGetDevice, Version: wgGenlVersion})
+	req.AddData(nl.NewRtAttr(wgDeviceIfname, nl.ZeroTerminated(name)))
+	msgs, err := req.Execute(unix.NETLINK_GENERIC, 0)
+	if err != nil {
+		return nil, err
+	}
+	var peers []Peer
+	for _, msg := range msgs {
+		attrs, err := nl.ParseRouteAttr(msg[nl.SizeofGenlmsg:])
+		if err != nil {
+			return nil, err
+		}
+		for _, attr := range attrs {
+			if attr.Attr.Type&^attrFlags != wgDevicePeers {
+				continue
+			}
+			entries, err := nl.ParseRouteAttr(attr.Value)
+			if err != nil {
+				return nil, err
+			}
+			for _, entry := range entries {
+				peer, err := parsePeer(entry.Value)
+				if err != nil {
+					return nil, err
+				}
+				peers = append(peers, peer)
+			}
+		}
+	}
+	return peers, nil
+}
+
+func parsePeer(b []byte) (Peer, error) {
+	attrs, err := nl.ParseRouteAttr(b)
+	if err != nil {
+		return Peer{}, err
+	}
+	native := nl.NativeEndian()
+	var peer Peer
+	for _, attr := range attrs {
+		v := attr.Value
+		switch attr.Attr.Type &^ attrFlags {
+		case wgPeerPublicKey:
+			peer.PublicKey = base64.StdEncoding.EncodeToString(v)
+		case wgPeerEndpoint:
+			peer.Endpoint = parseSockaddr(v)
+		case wgPeerHandshake:
+			// struct __kernel_timespec
+			if len(v) >= 16 {
+				sec, nsec := int64(native.Uint64(v)), int64(native.Uint64(v[8:]))
+				if sec != 0 || nsec != 0 {
+					peer.LastHandshake = time.Unix(sec, nsec)
+				}
+			}
+		case wgPeerRxBytes:
+			if len(v) >= 8 {
+				peer.Received = unit.Datasize(native.Uint64(v)) * unit.Byte
+			}
+		case wgPeerTxBytes:
+			if len(v) >= 8 {
+				peer.Sent = unit.Datasize(native.Uint64(v)) * unit.Byte
+			}
+		}
+	}
+	return peer, nil
+}
+
+// parseSockaddr formats a struct sockaddr_in or sockaddr_in6 as host:port.
+func parseSockaddr(b []byte) string {
+	if len(b) < 4 {
+		return ""
+	}
+	port := strconv.Itoa(int(binary.BigEndian.Uint16(b[2:])))
+	switch nl.NativeEndian().Uint16(b) {
+	case unix.AF_INET:
+		if len(b) >= 8 {
+			return net.JoinHostPort(net.IP(b[4:8]).String(), port)
+		}
+	case unix.AF_INET6:
+		if len(b) >= 24 {
+			return net.JoinHostPort(net.IP(b[8:24]).String(), port)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,130 @@
+package vpn
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/martinlindhe/unit"
+	"github.com/vishvananda/netlink/nl"
+	"golang.org/x/sys/unix"
+)
+
+// attrs serializes netlink attributes the way the kernel sends them.
+func attrs(list ...*nl.RtAttr) []byte {
+	var b []byte
+	for _, a := range list {
+		b = append(b, a.Serialize()...)
+	}
+	return b
+}
+
+func u64(v uint64) []byte {
+	b := make([]byte, 8)
+	nl.NativeEndian().PutUint64(b, v)
+	return b
+}
+
+// sockaddr builds a struct sockaddr_in or sockaddr_in6.
+func sockaddr(ip net.IP, port uint16) []byte {
+	var b []byte
+	if ip4 := ip.To4(); ip4 != nil {
+		b = make([]byte, 16)
+		nl.NativeEndian().PutUint16(b, unix.AF_INET)
+		copy(b[4:], ip4)
+	} else {
+		b = make([]byte, 28)
+		nl.NativeEndian().PutUint16(b, unix.AF_INET6)
+		copy(b[8:], ip.To16())
+	}
+	binary.BigEndian.PutUint16(b[2:], port)
+	return b
+}
+
+func TestParsePeer(t *testing.T) {
+	key := make([]byte, 32)
+	key[0] = 0xff
+	handshake := time.Unix(1700000000, 500)
+	timespec := append(u64(uint64(handshake.Unix())), u64(uint64(handshake.Nanosecond()))...)
+
+	for _, tc := range []struct {
+		name string
+		data []byte
+		want Peer
+	}{
+		{
+			"ipv4",
+			attrs(
+				nl.NewRtAttr(wgPeerPublicKey, key),
+				nl.NewRtAttr(wgPeerEndpoint, sockaddr(net.ParseIP("192.0.2.1"), 51820)),
+				nl.NewRtAttr(wgPeerHandshake, timespec),
+				nl.NewRtAttr(wgPeerRxBytes, u64(2048)),
+				nl.NewRtAttr(wgPeerTxBytes, u64(1024)),
+			),
+			Peer{
+				PublicKey:     "/wAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=",
+				Endpoint:      "192.0.2.1:51820",
+				LastHandshake: handshake,
+				Received:      2048 * unit.Byte,
+				Sent:          1024 * unit.Byte,
+			},
+		},
+		{
+			"ipv6",
+			attrs(nl.NewRtAttr(wgPeerEndpoint, sockaddr(net.ParseIP("2001:db8::1"), 443))),
+			Peer{Endpoint: "[2001:db8::1]:443"},
+		},
+		{
+			"no handshake yet",
+			attrs(nl.NewRtAttr(wgPeerHandshake, make([]byte, 16))),
+			Peer{},
+		},
+		{
+			"nested flag",
+			attrs(nl.NewRtAttr(wgPeerRxBytes|unix.NLA_F_NESTED, u64(7))),
+			Peer{Received: 7 * unit.Byte},
+		},
+		{
+			"truncated values",
+			attrs(
+				nl.NewRtAttr(wgPeerEndpoint, []byte{2, 0}),
+				nl.NewRtAttr(wgPeerHandshake, make([]byte, 8)),
+				nl.NewRtAttr(wgPeerTxBytes, []byte{1}),
+			),
+			Peer{},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			peer, err := parsePeer(tc.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !peer.LastHandshake.Equal(tc.want.LastHandshake) {
+				t.Errorf("got handshake %v, want %v", peer.LastHandshake, tc.want.LastHandshake)
+			}
+			peer.LastHandshake, tc.want.LastHandshake = time.Time{}, time.Time{}
+			if peer != tc.want {
+				t.Errorf("got %+v, want %+v", peer, tc.want)
+			}
+		})
+	}
+}
+
+func TestParseSockaddr(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"ipv4", sockaddr(net.ParseIP("10.0.0.1"), 1), "10.0.0.1:1"},
+		{"ipv6", sockaddr(net.ParseIP("fe80::1"), 65535), "[fe80::1]:65535"},
+		{"short", []byte{2, 0, 0}, ""},
+		{"short ipv4", sockaddr(net.ParseIP("10.0.0.1"), 1)[:6], ""},
+		{"unknown family", make([]byte, 16), ""},
+	} {
+		if got := parseSockaddr(tc.data); got != tc.want {
+			t.Errorf("%s: got %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package x509info
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"github.com/tionis/i3-tools/bar/certwatch"
+	"golang.org/x/crypto/pkcs12"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Cert is the leaf certificate of a PEM file or PKCS#12 bundle together with
+// the file it was loaded from. If the file could not be parsed, Certificate
+// is nil and Err is set. ChainErr is set if the chain could not be verified.
+type Cert struct {
+	Path string
+	*x509.Certificate
+	Err      error
+	ChainErr error
+}
+
+// Name returns the common name of the certificate subject, or the file name
+// if the subject has no common name.
+func (c Cert) Name() string {
+	if c.Certificate != nil && c.Subject.CommonName != "" {
+		return c.Subject.CommonName
+	}
+	return filepath.Base(c.Path)
+}
+
+// Validity returns the time passed since the certificate became valid and the
+// time remaining until it expires, e.g. "80.0d/10.0d".
+func (c Cert) Validity() string {
+	timePassed, timeRemaining := validity(c.Certificate)
+	return fmt.Sprintf("%s/%s", certwatch.Duration(timePassed), certwatch.Duration(timeRemaining))
+}
+
+// Remaining returns the time remaining until the certificate expires.
+func (c Cert) Remaining() string {
+	_, timeRemaining := validity(c.Certificate)
+	return certwatch.Duration(timeRemaining)
+}
+
+// RemainingFraction returns the fraction of the validity period that is
+// left, from 1 for a certificate that just became valid to 0 once expired.
+func (c Cert) RemainingFraction() float64 {
+	return certwatch.RemainingFraction(validity(c.Certificate))
+}
+
+// validity returns the time passed since the certificate became valid and
+// the time remaining until it expires.
+func validity(cert *x509.Certificate) (timePassed, timeRemaining time.Duration) {
+	now := time.Now()
+	if now.After(cert.NotBefore) {
+		timePassed = now.Sub(cert.NotBefore)
+	}
+	if now.Before(cert.NotAfter) {
+		timeRemaining = cert.NotAfter.Sub(now)
+	}
+	return timePassed, timeRemaining
+}
+
+// isPKCS12 reports whether the file should be parsed as a PKCS#12 bundle.
+func isPKCS12(certPath string) bool {
+	ext := strings.ToLower(filepath.Ext(certPath))
+	return ext == ".p12" || ext == ".pfx"
+}
+
+// parseCertFile reads all certificates from a PEM file or PKCS#12 bundle.
+// Only bundles using the legacy encryption and SHA-1 MAC are supported,
+// which OpenSSL 3 only exports with -legacy.
+func parseCertFile(certPath, password string) ([]*x509.Certificate, error) {
+	data, err := os.ReadFile(certPath)
+	if err != nil {
+		return nil, err
+	}
+	var blocks []*pem.Block
+	if isPKCS12(certPath) {
+		blocks, err = pkcs12.ToPEM(data, password)
+		var unsupported pkcs12.NotImplementedError
+		if errors.As(err, &unsupported) {
+			return nil, fmt.Errorf("unsupported PKCS#12 encryption, export it with openssl pkcs12 -legacy or as PEM (%v)", err)
+		}
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		for {
+			var block *pem.Block
+			block, data = pem.Decode(data)
+			if block == nil {
+				break
+			}
+			blocks = append(blocks, block)
+		}
+	}
+	var certs []*x509.Certificate
+	for _, block := range blocks {
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("no certificate found in %s", certPath)
+	}
+	return certs, nil
+}
+
+// leaf returns the end-entity certificate of a bundle and the remaining
+// certificates, which are used as intermediates during verification.
+func leaf(certs []*x509.Certificate) (*x509.Certificate, []*x509.Certificate) {
+	index := 0
+	for i, cert := range certs {
+		if !cert.IsCA {
+			index = i
+			break
+		}
+	}
+	rest := make([]*x509.Certificate, 0, len(certs)-1)
+	rest = append(rest, certs[:index]...)
+	rest = append(rest, certs[index+1:]...)
+	return certs[index], rest
+}
+
+// verify checks the chain of the leaf certificate against the given roots,
+// or the system roots if roots is nil. Self-signed certificates, such as a
+// local CA, are not verified. The expiry of the leaf itself is shown by the
+// remaining validity, so the chain is checked at a time the leaf is valid.
+func verify(cert *x509.Certificate, intermediates []*x509.Certificate, roots *x509.CertPool) error {
+	if cert.IsCA && cert.CheckSignatureFrom(cert) == nil {
+		return nil
+	}
+	pool := x509.NewCertPool()
+	for _, intermediate := range intermediates {
+		pool.AddCert(intermediate)
+	}
+	now := time.Now()
+	if now.After(cert.NotAfter) {
+		now = cert.NotAfter
+	} else if now.Before(cert.NotBefore) {
+		now = cert.NotBefore
+	}
+	_, err := cert.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: pool,
+		CurrentTime:   now,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	return err
+}
@@ -0,0 +1,57 @@
+package x509info
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseCertFile(t *testing.T) {
+	for _, path := range []string{"testdata/client.pem", "testdata/legacy.p12"} {
+		certs, err := parseCertFile(path, "secret")
+		if err != nil {
+			t.Errorf("%s: %v", path, err)
+			continue
+		}
+		if len(certs) != 1 || certs[0].Subject.CommonName != "client.example" {
+			t.Errorf("%s: got %d certificates", path, len(certs))
+		}
+	}
+}
+
+func TestParseCertFileErrors(t *testing.T) {
+	for _, tc := range []struct {
+		path, password, want string
+	}{
+		// OpenSSL 3 encrypts with AES and uses a SHA-256 MAC by default.
+		{"testdata/modern.p12", "secret", "export it with openssl pkcs12 -legacy"},
+		{"testdata/legacy.p12", "wrong", "password incorrect"},
+		{"testdata/missing.pem", "", "no such file"},
+		{"cert_test.go", "", "no certificate found"},
+	} {
+		_, err := parseCertFile(tc.path, tc.password)
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: got error %v, want %q", tc.path, err, tc.want)
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	certs, err := parseCertFile("testdata/client.pem", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert := Cert{Path: "testdata/client.pem", Certificate: certs[0]}
+	if got := cert.Name(); got != "client.example" {
+		t.Errorf("got name %q", got)
+	}
+	if got := cert.RemainingFraction(); got < 0.99 {
+		t.Errorf("got remaining fraction %v for a new certificate", got)
+	}
+	// The shared rendering of certwatch.Duration.
+	if got := cert.Validity(); !strings.HasSuffix(got, "d") || !strings.Contains(got, "/") {
+		t.Errorf("got validity %q", got)
+	}
+	if got := (Cert{Path: "testdata/client.pem"}).Name(); got != "client.pem" {
+		t.Errorf("got name %q without a certificate", got)
+	}
+}
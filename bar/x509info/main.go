@@ -0,0 +1,197 @@
+// Package x509info provides an indicator for the remaining validity of X.509
+// certificates, such as client, VPN or local CA certificates.
+package x509info
+
+import (
+	"barista.run/bar"
+	"barista.run/base/value"
+	"barista.run/colors"
+	"barista.run/outputs"
+	"context"
+	"crypto/x509"
+	"fmt"
+	"github.com/tionis/i3-tools/bar/certwatch"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Module represents a x509info barista module that shows the remaining
+// validity of the certificate expiring soonest.
+type Module struct {
+	patterns   []string
+	outputFunc value.Value // of func([]Cert) bar.Output
+	format     *template.Template
+	formatErr  error
+	showAll    bool
+	password   string
+	roots      *x509.CertPool
+	rootsErr   error
+}
+
+// loadCerts expands all configured patterns and parses every matching file.
+// Certificates are sorted by expiry, soonest first, followed by the files that
+// could not be parsed.
+func (m *Module) loadCerts() []Cert {
+	var certs []Cert
+	certwatch.Glob(m.patterns, func(certPath string, err error) {
+		var bundle []*x509.Certificate
+		if err == nil {
+			bundle, err = parseCertFile(certPath, m.password)
+		}
+		if err != nil {
+			certs = append(certs, Cert{Path: certPath, Err: err})
+			return
+		}
+		cert, intermediates := leaf(bundle)
+		chainErr := m.rootsErr
+		if chainErr == nil {
+			chainErr = verify(cert, intermediates, m.roots)
+		}
+		certs = append(certs, Cert{Path: certPath, Certificate: cert, ChainErr: chainErr})
+	})
+	sort.SliceStable(certs, func(i, j int) bool {
+		if certs[i].Err != nil || certs[j].Err != nil {
+			return certs[i].Err == nil && certs[j].Err != nil
+		}
+		return certs[i].NotAfter.Before(certs[j].NotAfter)
+	})
+	return certs
+}
+
+// ForPaths constructs a x509info module for the given PEM files or PKCS#12
+// bundles (*.p12, *.pfx). PKCS#12 bundles with the AES encryption OpenSSL 3
+// uses by default are shown as an error, they need to be exported with
+// -legacy. Paths may contain glob patterns, files that do not exist (yet)
+// are ignored until they appear. The format is a text/template
+// executed with a Cert, e.g. "[{{.Name}} {{.Remaining}}]".
+func ForPaths(format string, paths ...string) *Module {
+	m := &Module{patterns: paths}
+	m.format, m.formatErr = template.New("x509info").Parse(format)
+
+	m.Output(func(certs []Cert) bar.Output {
+		return certwatch.Output(certs, m.showAll, m.renderCert)
+	})
+	return m
+}
+
+// ShowAll configures the module to display every certificate instead of
+// only the one expiring soonest.
+func (m *Module) ShowAll(showAll bool) *Module {
+	m.showAll = showAll
+	return m
+}
+
+// Password sets the password used to decrypt PKCS#12 bundles.
+func (m *Module) Password(password string) *Module {
+	m.password = password
+	return m
+}
+
+// Roots sets the PEM files containing the CA certificates used to verify the
+// certificate chains. By default, the system roots are used.
+func (m *Module) Roots(paths ...string) *Module {
+	m.roots, m.rootsErr = x509.SystemCertPool()
+	if m.rootsErr != nil {
+		m.roots = x509.NewCertPool()
+		m.rootsErr = nil
+	}
+	for _, rootPath := range paths {
+		data, err := os.ReadFile(rootPath)
+		if err != nil {
+			m.rootsErr = err
+			return m
+		}
+		if !m.roots.AppendCertsFromPEM(data) {
+			m.rootsErr = fmt.Errorf("no certificate found in %s", rootPath)
+			return m
+		}
+	}
+	return m
+}
+
+// Output sets the output format for the module.
+func (m *Module) Output(outputFunc func([]Cert) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+func (m *Module) renderCert(cert Cert) *bar.Segment {
+	if cert.Err != nil {
+		return outputs.Errorf("%s: %v", filepath.Base(cert.Path), cert.Err)
+	}
+	if m.formatErr != nil {
+		return outputs.Error(m.formatErr)
+	}
+	var text strings.Builder
+	if err := m.format.Execute(&text, cert); err != nil {
+		return outputs.Error(err)
+	}
+	if cert.ChainErr != nil {
+		text.WriteString(" (chain invalid)")
+	}
+	out := outputs.Text(text.String())
+	remainingFraction := cert.RemainingFraction()
+	scheme := certwatch.ColorScheme(remainingFraction)
+	if scheme == "good" && cert.ChainErr != nil {
+		scheme = "degraded"
+	}
+	out.Color(colors.Scheme(scheme))
+	if remainingFraction == 0 {
+		out.Urgent(true)
+	}
+	return out
+}
+
+// refreshInterval returns the refresh interval needed by the certificate
+// closest to a validity boundary.
+func refreshInterval(certs []Cert) time.Duration {
+	if len(certs) == 0 {
+		// Poll for certificates that do not exist yet.
+		return time.Minute
+	}
+	interval := time.Hour
+	for _, cert := range certs {
+		if cert.Err == nil {
+			interval = min(interval, certwatch.Interval(validity(cert.Certificate)))
+		}
+	}
+	return interval
+}
+
+// Stream starts the module.
+func (m *Module) Stream(sink bar.Sink) {
+	m.StreamContext(context.Background(), sink)
+}
+
+// StreamContext starts the module and stops it once ctx is cancelled.
+func (m *Module) StreamContext(ctx context.Context, sink bar.Sink) {
+	watcher := certwatch.Watch(m.patterns, time.Minute)
+	defer watcher.Close()
+	outputFunc := m.outputFunc.Get().(func([]Cert) bar.Output)
+	nextOutputFunc, done := m.outputFunc.Subscribe()
+	defer done()
+
+	var certs []Cert
+	refresh := func() {
+		certs = m.loadCerts()
+		watcher.Reset(refreshInterval(certs))
+		sink.Output(outputFunc(certs))
+	}
+	refresh()
+
+	for {
+		select {
+		case <-watcher.C:
+			refresh()
+		case <-nextOutputFunc:
+			outputFunc = m.outputFunc.Get().(func([]Cert) bar.Output)
+			sink.Output(outputFunc(certs))
+		case <-ctx.Done():
+			return
+		}
+	}
+}
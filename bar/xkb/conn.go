@@ -0,0 +1,161 @@
+package xkb
+
+import (
+	"log"
+	"sort"
+	"sync"
+
+	"github.com/BurntSushi/xgb"
+)
+
+// shared is the X connection used by all modules. xgb keeps event
+// constructors in global tables, so a single connection receives the events
+// and dispatches them to the subscribers without ever blocking on them.
+var shared struct {
+	sync.Mutex
+	conn        *xgb.Conn
+	subscribers map[*Subscriber]struct{}
+}
+
+// Subscriber receives the keyboard events for one module. Instead of
+// queueing them, only the latest event of each type is kept until the module
+// picks it up, so a busy module never misses the current state.
+type Subscriber struct {
+	mu      sync.Mutex
+	pending map[byte]Event // by type
+	lost    bool
+	ready   chan struct{}
+}
+
+func newSubscriber() *Subscriber {
+	return &Subscriber{
+		pending: make(map[byte]Event),
+		ready:   make(chan struct{}, 1),
+	}
+}
+
+// Ready receives a value when there are events to pick up or the connection
+// was lost.
+func (s *Subscriber) Ready() <-chan struct{} {
+	return s.ready
+}
+
+// Receive returns and clears the pending events, ordered by type. It reports
+// false once the connection is lost.
+func (s *Subscriber) Receive() ([]Event, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	events := make([]Event, 0, len(s.pending))
+	for typ, ev := range s.pending {
+		events = append(events, ev)
+		delete(s.pending, typ)
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].Type < events[j].Type })
+	return events, !s.lost
+}
+
+// send replaces the pending event of the same type.
+func (s *Subscriber) send(ev Event) {
+	s.mu.Lock()
+	s.pending[ev.Type] = ev
+	s.mu.Unlock()
+	s.notify()
+}
+
+// close marks the connection as lost.
+func (s *Subscriber) close() {
+	s.mu.Lock()
+	s.lost = true
+	s.mu.Unlock()
+	s.notify()
+}
+
+func (s *Subscriber) notify() {
+	select {
+	case s.ready <- struct{}{}:
+	default:
+	}
+}
+
+// Subscribe returns the shared X connection and a subscriber receiving the
+// keyboard events, connecting on first use. The returned function
+// unsubscribes and closes the connection once it is unused.
+func Subscribe() (*xgb.Conn, *Subscriber, func(), error) {
+	shared.Lock()
+	defer shared.Unlock()
+	if shared.conn == nil {
+		conn, err := connect()
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		shared.conn = conn
+		shared.subscribers = make(map[*Subscriber]struct{})
+		go dispatch(conn)
+	}
+	conn := shared.conn
+	sub := newSubscriber()
+	shared.subscribers[sub] = struct{}{}
+	return conn, sub, func() {
+		shared.Lock()
+		defer shared.Unlock()
+		if _, ok := shared.subscribers[sub]; !ok || shared.conn != conn {
+			return
+		}
+		delete(shared.subscribers, sub)
+		if len(shared.subscribers) == 0 {
+			shared.conn = nil
+			conn.Close()
+		}
+	}, nil
+}
+
+func connect() (*xgb.Conn, error) {
+	conn, err := xgb.NewConn()
+	if err != nil {
+		return nil, err
+	}
+	if err := Init(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	types := uint16(1<<NewKeyboardNotify | 1<<StateNotify | 1<<NamesNotify)
+	if err := SelectEvents(conn, types); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+func dispatch(conn *xgb.Conn) {
+	for {
+		ev, err := conn.WaitForEvent()
+		if ev == nil && err == nil {
+			break
+		}
+		if err != nil {
+			log.Printf("xkb: %v", err)
+			continue
+		}
+		xkbEvent, ok := ev.(Event)
+		if !ok {
+			continue
+		}
+		shared.Lock()
+		if shared.conn == conn {
+			for sub := range shared.subscribers {
+				sub.send(xkbEvent)
+			}
+		}
+		shared.Unlock()
+	}
+	shared.Lock()
+	defer shared.Unlock()
+	if shared.conn != conn {
+		return
+	}
+	for sub := range shared.subscribers {
+		sub.close()
+	}
+	shared.conn = nil
+	shared.subscribers = nil
+}
@@ -0,0 +1,48 @@
+package xkb
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSubscriberCoalesces(t *testing.T) {
+	s := newSubscriber()
+	for i := byte(0); i < 20; i++ {
+		s.send(Event{Type: StateNotify, State: State{LockedMods: i}})
+	}
+	s.send(Event{Type: NamesNotify})
+	s.send(Event{Type: NewKeyboardNotify})
+
+	select {
+	case <-s.Ready():
+	default:
+		t.Fatal("subscriber not ready after events")
+	}
+	want := []Event{
+		{Type: NewKeyboardNotify},
+		{Type: StateNotify, State: State{LockedMods: 19}},
+		{Type: NamesNotify},
+	}
+	if got, ok := s.Receive(); !ok || !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v and %v, want %v", got, ok, want)
+	}
+	if got, ok := s.Receive(); !ok || len(got) != 0 {
+		t.Errorf("got %v and %v after receiving, want nothing", got, ok)
+	}
+}
+
+func TestSubscriberLost(t *testing.T) {
+	s := newSubscriber()
+	s.send(Event{Type: StateNotify, State: State{Group: 1}})
+	<-s.Ready()
+	s.close()
+
+	select {
+	case <-s.Ready():
+	default:
+		t.Fatal("subscriber not ready after losing the connection")
+	}
+	if got, ok := s.Receive(); ok || len(got) != 1 {
+		t.Errorf("got %v and %v, want the pending event and the lost connection", got, ok)
+	}
+}
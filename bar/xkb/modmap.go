@@ -0,0 +1,46 @@
+package xkb
+
+import (
+	"github.com/BurntSushi/xgb"
+	"github.com/BurntSushi/xgb/xproto"
+)
+
+// numLockKeysym is the Num_Lock keysym.
+const numLockKeysym = 0xff7f
+
+// NumLockMask returns the modifier Num_Lock is mapped to, or Mod2Mask if it
+// is not mapped to any.
+func NumLockMask(c *xgb.Conn) (byte, error) {
+	setup := xproto.Setup(c)
+	count := byte(setup.MaxKeycode - setup.MinKeycode + 1)
+	keymap, err := xproto.GetKeyboardMapping(c, setup.MinKeycode, count).Reply()
+	if err != nil {
+		return 0, err
+	}
+	modmap, err := xproto.GetModifierMapping(c).Reply()
+	if err != nil {
+		return 0, err
+	}
+	perKeycode := int(keymap.KeysymsPerKeycode)
+	isNumLock := func(keycode xproto.Keycode) bool {
+		if keycode < setup.MinKeycode || keycode > setup.MaxKeycode {
+			return false
+		}
+		first := int(keycode-setup.MinKeycode) * perKeycode
+		for _, sym := range keymap.Keysyms[first : first+perKeycode] {
+			if sym == numLockKeysym {
+				return true
+			}
+		}
+		return false
+	}
+	perMod := int(modmap.KeycodesPerModifier)
+	for mod := 0; mod < 8; mod++ {
+		for _, keycode := range modmap.Keycodes[mod*perMod : (mod+1)*perMod] {
+			if keycode != 0 && isNumLock(keycode) {
+				return 1 << mod, nil
+			}
+		}
+	}
+	return Mod2Mask, nil
+}
@@ -0,0 +1,219 @@
+// Package xkb is a minimal client for the X keyboard extension, covering
+// only what the keyboard indicators need: reading and locking the keyboard
+// state and receiving its change events. xgb does not ship the extension, so
+// the requests are encoded here the same way xgb's generated packages do.
+package xkb
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/BurntSushi/xgb"
+	"github.com/BurntSushi/xgb/xproto"
+)
+
+const extName = "XKEYBOARD"
+
+// Request opcodes.
+const (
+	useExtension   = 0
+	selectEvents   = 1
+	getState       = 4
+	latchLockState = 5
+)
+
+// useCoreKbd selects the core keyboard as the device.
+const useCoreKbd = 0x100
+
+// Event types, sent as the second byte of the single XKB event code.
+const (
+	NewKeyboardNotify = 0
+	StateNotify       = 2
+	NamesNotify       = 6
+)
+
+// Modifier masks of the core protocol.
+const (
+	ShiftMask = 1 << iota
+	LockMask
+	ControlMask
+	Mod1Mask
+	Mod2Mask
+	Mod3Mask
+	Mod4Mask
+	Mod5Mask
+)
+
+// State is the keyboard state of the core keyboard.
+type State struct {
+	// Mods are the effective modifiers and LockedMods the locked ones,
+	// e.g. LockMask while Caps Lock is on.
+	Mods, LockedMods byte
+	// Group is the effective layout group.
+	Group byte
+}
+
+// Event is an XKB event. State is only set for StateNotify events.
+type Event struct {
+	Type  byte
+	State State
+	raw   []byte
+}
+
+// Bytes returns the raw event as required by xgb.Event.
+func (e Event) Bytes() []byte {
+	return e.raw
+}
+
+// String implements xgb.Event.
+func (e Event) String() string {
+	return xgb.Sprintf("XkbEvent {Type: %d, State: %+v}", e.Type, e.State)
+}
+
+func init() {
+	xgb.NewExtEventFuncs[extName] = map[int]xgb.NewEventFun{
+		0: func(buf []byte) xgb.Event {
+			ev := Event{Type: buf[1], raw: buf}
+			if ev.Type == StateNotify {
+				ev.State = State{Mods: buf[9], LockedMods: buf[12], Group: buf[13]}
+			}
+			return ev
+		},
+	}
+	xgb.NewExtErrorFuncs[extName] = make(map[int]xgb.NewErrorFun)
+}
+
+// initMu serializes Init, which registers the event constructor in xgb's
+// global tables.
+var initMu sync.Mutex
+
+// Init queries the extension, registers its events and negotiates the
+// protocol version. It must be called before any other request.
+func Init(c *xgb.Conn) error {
+	initMu.Lock()
+	defer initMu.Unlock()
+	reply, err := xproto.QueryExtension(c, uint16(len(extName)), extName).Reply()
+	switch {
+	case err != nil:
+		return err
+	case !reply.Present:
+		return xgb.Errorf("No extension named %s could be found on on the server.", extName)
+	}
+	c.ExtLock.Lock()
+	c.Extensions[extName] = reply.MajorOpcode
+	c.ExtLock.Unlock()
+	for evNum, fun := range xgb.NewExtEventFuncs[extName] {
+		xgb.NewEventFuncs[int(reply.FirstEvent)+evNum] = fun
+	}
+
+	buf := request(c, useExtension, 8)
+	xgb.Put16(buf[4:], 1) // wanted major
+	xgb.Put16(buf[6:], 0) // wanted minor
+	cookie := c.NewCookie(true, true)
+	c.NewRequest(buf, cookie)
+	ext, err := cookie.Reply()
+	if err != nil {
+		return err
+	}
+	if ext[1] == 0 {
+		return xgb.Errorf("%s version 1.0 is not supported by the server", extName)
+	}
+	return nil
+}
+
+// request returns a request buffer of the given size with the header set.
+func request(c *xgb.Conn, opcode byte, size int) []byte {
+	buf := make([]byte, size)
+	c.ExtLock.RLock()
+	buf[0] = c.Extensions[extName]
+	c.ExtLock.RUnlock()
+	buf[1] = opcode
+	xgb.Put16(buf[2:], uint16(size/4))
+	return buf
+}
+
+// SelectEvents selects all details of the given event types (as a mask of
+// 1<<type) for the core keyboard.
+func SelectEvents(c *xgb.Conn, types uint16) error {
+	buf := request(c, selectEvents, 16)
+	xgb.Put16(buf[4:], useCoreKbd)
+	xgb.Put16(buf[6:], types)  // affect which
+	xgb.Put16(buf[8:], 0)      // clear
+	xgb.Put16(buf[10:], types) // select all
+	cookie := c.NewCookie(true, false)
+	c.NewRequest(buf, cookie)
+	return cookie.Check()
+}
+
+// GetState returns the state of the core keyboard.
+func GetState(c *xgb.Conn) (State, error) {
+	buf := request(c, getState, 8)
+	xgb.Put16(buf[4:], useCoreKbd)
+	cookie := c.NewCookie(true, true)
+	c.NewRequest(buf, cookie)
+	reply, err := cookie.Reply()
+	if err != nil {
+		return State{}, err
+	}
+	return State{Mods: reply[8], LockedMods: reply[11], Group: reply[12]}, nil
+}
+
+// LockGroup locks the layout group of the core keyboard.
+func LockGroup(c *xgb.Conn, group byte) error {
+	buf := request(c, latchLockState, 16)
+	xgb.Put16(buf[4:], useCoreKbd)
+	buf[8] = 1 // lock group
+	buf[9] = group
+	cookie := c.NewCookie(true, false)
+	c.NewRequest(buf, cookie)
+	return cookie.Check()
+}
+
+// LockMods locks or unlocks the given modifiers of the core keyboard.
+func LockMods(c *xgb.Conn, affect, locks byte) error {
+	buf := request(c, latchLockState, 16)
+	xgb.Put16(buf[4:], useCoreKbd)
+	buf[6] = affect
+	buf[7] = locks
+	cookie := c.NewCookie(true, false)
+	c.NewRequest(buf, cookie)
+	return cookie.Check()
+}
+
+// RulesNames are the names the keymap was compiled from, as set by
+// setxkbmap on the root window.
+type RulesNames struct {
+	Rules, Model string
+	// Layouts and Variants hold one entry per group.
+	Layouts, Variants []string
+	Options           string
+}
+
+// GetRulesNames reads the _XKB_RULES_NAMES property of the root window.
+func GetRulesNames(c *xgb.Conn) (RulesNames, error) {
+	const name = "_XKB_RULES_NAMES"
+	atom, err := xproto.InternAtom(c, true, uint16(len(name)), name).Reply()
+	if err != nil {
+		return RulesNames{}, err
+	}
+	root := xproto.Setup(c).DefaultScreen(c).Root
+	prop, err := xproto.GetProperty(c, false, root, atom.Atom, xproto.AtomString, 0, 1024).Reply()
+	if err != nil {
+		return RulesNames{}, err
+	}
+	fields := strings.Split(string(prop.Value), "\x00")
+	for len(fields) < 5 {
+		fields = append(fields, "")
+	}
+	names := RulesNames{
+		Rules:    fields[0],
+		Model:    fields[1],
+		Layouts:  strings.Split(fields[2], ","),
+		Variants: strings.Split(fields[3], ","),
+		Options:  fields[4],
+	}
+	for len(names.Variants) < len(names.Layouts) {
+		names.Variants = append(names.Variants, "")
+	}
+	return names, nil
+}
@@ -0,0 +1,200 @@
+package yubikey
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/maximbaz/yubikey-touch-detector/detector"
+	ykNotifier "github.com/maximbaz/yubikey-touch-detector/notifier"
+)
+
+// The gpg, u2f and hmac detectors cannot be stopped, so they are started at
+// most once per process and shared by all modules. Their messages are
+// dispatched to the currently streaming modules without ever blocking the
+// detectors. The ssh detector replaces the agent socket and is therefore
+// only kept running while a module needs it.
+var detectors struct {
+	sync.Mutex
+	started         Source
+	notifiers       *sync.Map
+	requestGPGCheck chan bool
+	requestSSHCheck chan bool
+	subscribers     map[*subscriber]struct{}
+	sshUsers        int
+	ssh             *sshDetector
+	lastSSH         atomic.Int64
+}
+
+// subscriber receives the messages of the detectors for one module. Instead
+// of queueing them, only the latest message of each detector is kept until
+// the module picks it up, so a busy module never misses that a touch is no
+// longer pending.
+type subscriber struct {
+	mu      sync.Mutex
+	pending map[string]ykNotifier.Message // by detector
+	ready   chan struct{}
+}
+
+func newSubscriber() *subscriber {
+	return &subscriber{
+		pending: make(map[string]ykNotifier.Message),
+		ready:   make(chan struct{}, 1),
+	}
+}
+
+// send replaces the pending message of the detector that sent msg.
+func (s *subscriber) send(msg ykNotifier.Message) {
+	s.mu.Lock()
+	// All messages are named after their detector, e.g. GPG_1 and GPG_0.
+	s.pending[string(msg[:3])] = msg
+	s.mu.Unlock()
+	select {
+	case s.ready <- struct{}{}:
+	default:
+	}
+}
+
+// receive returns and clears the pending messages, ordered by detector.
+func (s *subscriber) receive() []ykNotifier.Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	messages := make([]ykNotifier.Message, 0, len(s.pending))
+	for detector, msg := range s.pending {
+		messages = append(messages, msg)
+		delete(s.pending, detector)
+	}
+	sort.Slice(messages, func(i, j int) bool { return messages[i] < messages[j] })
+	return messages
+}
+
+// dispatch passes a message to all subscribers.
+func dispatch(msg ykNotifier.Message) {
+	detectors.Lock()
+	defer detectors.Unlock()
+	for s := range detectors.subscribers {
+		s.send(msg)
+	}
+}
+
+// startShared starts the dispatcher and the gpg card check once.
+func startShared() {
+	if detectors.notifiers != nil {
+		return
+	}
+	messages := make(chan ykNotifier.Message, 10)
+	detectors.notifiers = new(sync.Map)
+	detectors.notifiers.Store("barista", messages)
+	detectors.requestGPGCheck = make(chan bool)
+	detectors.requestSSHCheck = make(chan bool)
+	if detectors.subscribers == nil {
+		detectors.subscribers = make(map[*subscriber]struct{})
+	}
+
+	go func() {
+		for msg := range messages {
+			dispatch(msg)
+		}
+	}()
+	go detector.CheckGPGOnRequest(detectors.requestGPGCheck, detectors.notifiers)
+
+	// The ssh detector requests the same card check as the gpg detector,
+	// so its requests are forwarded to remember when ssh was last active.
+	go func() {
+		for range detectors.requestSSHCheck {
+			detectors.lastSSH.Store(time.Now().UnixNano())
+			select {
+			case detectors.requestGPGCheck <- true:
+			default:
+			}
+		}
+	}()
+}
+
+// subscribe starts the detectors for the given sources if they are not
+// running yet and returns a subscriber receiving their messages. The returned
+// function unsubscribes and stops the ssh detector once it is unused.
+func subscribe(gpgPubringPath string, sources Source) (*subscriber, func()) {
+	detectors.Lock()
+	defer detectors.Unlock()
+	startShared()
+
+	missing := sources &^ detectors.started
+	if missing&GPG != 0 {
+		// Only the keyring of the first module is watched.
+		go detector.WatchGPG(gpgPubringPath, detectors.requestGPGCheck)
+	}
+	if missing&U2F != 0 {
+		go detector.WatchU2F(detectors.notifiers)
+	}
+	if missing&HMAC != 0 {
+		go detector.WatchHMAC(detectors.notifiers)
+	}
+	detectors.started |= missing &^ SSH
+
+	if sources&SSH != 0 {
+		detectors.sshUsers++
+		if detectors.sshUsers == 1 {
+			detectors.ssh = startSSH()
+		}
+	}
+
+	s := newSubscriber()
+	detectors.subscribers[s] = struct{}{}
+	return s, func() {
+		detectors.Lock()
+		defer detectors.Unlock()
+		delete(detectors.subscribers, s)
+		if sources&SSH != 0 {
+			detectors.sshUsers--
+			if detectors.sshUsers == 0 {
+				detectors.ssh.stop()
+				detectors.ssh = nil
+			}
+		}
+	}
+}
+
+// sshDetector is a running ssh detector. WatchSSH only registers its exit
+// channel once the proxy socket is set up, so the detector is tracked from
+// before it starts and stopping it waits for either.
+type sshDetector struct {
+	exits   *sync.Map
+	stopped chan struct{} // closed once WatchSSH returned
+}
+
+func startSSH() *sshDetector {
+	d := &sshDetector{exits: new(sync.Map), stopped: make(chan struct{})}
+	go func() {
+		defer close(d.stopped)
+		detector.WatchSSH(detectors.requestSSHCheck, d.exits)
+	}()
+	return d
+}
+
+// stop stops the ssh detector and waits for it to restore the original agent
+// socket. If the detector failed to set up the proxy, there is nothing to
+// restore.
+func (d *sshDetector) stop() {
+	for {
+		if exit, ok := d.exits.Load("detector/ssh"); ok {
+			ch := exit.(chan bool)
+			ch <- true
+			<-ch
+			<-d.stopped
+			return
+		}
+		select {
+		case <-d.stopped:
+			return
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+// sshActive reports whether the ssh agent was used recently, in which case a
+// pending gpg touch is attributed to ssh.
+func sshActive() bool {
+	return time.Since(time.Unix(0, detectors.lastSSH.Load())) < sshAttribution
+}
@@ -0,0 +1,125 @@
+package yubikey
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+
+	ykNotifier "github.com/maximbaz/yubikey-touch-detector/notifier"
+)
+
+func TestSubscriberCoalesces(t *testing.T) {
+	s := newSubscriber()
+	s.send(ykNotifier.GPG_ON)
+	s.send(ykNotifier.U2F_ON)
+	s.send(ykNotifier.GPG_OFF)
+
+	select {
+	case <-s.ready:
+	default:
+		t.Fatal("subscriber not ready after messages")
+	}
+	want := []ykNotifier.Message{ykNotifier.GPG_OFF, ykNotifier.U2F_ON}
+	if got := s.receive(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got := s.receive(); len(got) != 0 {
+		t.Errorf("got %v after receiving, want nothing", got)
+	}
+}
+
+// addSubscribers registers subscribers without starting any detector.
+func addSubscribers(t *testing.T, n int) []*subscriber {
+	subs := make([]*subscriber, n)
+	detectors.Lock()
+	defer detectors.Unlock()
+	if detectors.subscribers == nil {
+		detectors.subscribers = make(map[*subscriber]struct{})
+	}
+	for i := range subs {
+		subs[i] = newSubscriber()
+		detectors.subscribers[subs[i]] = struct{}{}
+	}
+	t.Cleanup(func() {
+		detectors.Lock()
+		defer detectors.Unlock()
+		for _, s := range subs {
+			delete(detectors.subscribers, s)
+		}
+	})
+	return subs
+}
+
+func TestDispatchNeverLosesOff(t *testing.T) {
+	subs := addSubscribers(t, 5)
+	m := ForPath("").Sources(GPG | U2F | HMAC)
+
+	// The modules consume their messages concurrently with the detectors
+	// sending them, and the last message of every detector is an OFF.
+	done := make(chan struct{})
+	touches := make([]Touch, len(subs))
+	var consumers sync.WaitGroup
+	for i, s := range subs {
+		consumers.Add(1)
+		go func(i int, s *subscriber) {
+			defer consumers.Done()
+			for {
+				select {
+				case <-s.ready:
+					for _, msg := range s.receive() {
+						touches[i] = m.apply(touches[i], msg)
+					}
+				case <-done:
+					for _, msg := range s.receive() {
+						touches[i] = m.apply(touches[i], msg)
+					}
+					return
+				}
+			}
+		}(i, s)
+	}
+
+	var senders sync.WaitGroup
+	for _, pair := range [][2]ykNotifier.Message{
+		{ykNotifier.GPG_ON, ykNotifier.GPG_OFF},
+		{ykNotifier.U2F_ON, ykNotifier.U2F_OFF},
+		{ykNotifier.HMAC_ON, ykNotifier.HMAC_OFF},
+	} {
+		senders.Add(1)
+		go func(on, off ykNotifier.Message) {
+			defer senders.Done()
+			for i := 0; i < 1000; i++ {
+				dispatch(on)
+				dispatch(off)
+			}
+		}(pair[0], pair[1])
+	}
+	senders.Wait()
+	close(done)
+	consumers.Wait()
+
+	for i, touch := range touches {
+		if touch.Pending() {
+			t.Errorf("subscriber %d still shows %v pending", i, touch.Reasons())
+		}
+	}
+}
+
+func TestApply(t *testing.T) {
+	m := ForPath("").Sources(GPG | HMAC)
+	var touch Touch
+	touch = m.apply(touch, ykNotifier.U2F_ON)
+	if touch.Pending() {
+		t.Errorf("u2f touch shown although u2f is not watched: %+v", touch)
+	}
+	touch = m.apply(touch, ykNotifier.HMAC_ON)
+	touch = m.apply(touch, ykNotifier.GPG_ON)
+	if want := (Touch{GPG: true, HMAC: true}); touch != want {
+		t.Errorf("got %+v, want %+v", touch, want)
+	}
+	touch = m.apply(touch, ykNotifier.GPG_OFF)
+	touch = m.apply(touch, ykNotifier.HMAC_OFF)
+	if touch.Pending() {
+		t.Errorf("touch still pending after all detectors reported off: %+v", touch)
+	}
+}
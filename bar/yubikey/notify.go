@@ -0,0 +1,133 @@
+package yubikey
+
+import (
+	"log"
+	"os/exec"
+	"strings"
+	"sync/atomic"
+
+	"github.com/esiqveland/notify"
+	"github.com/godbus/dbus/v5"
+)
+
+// DefaultSound is played for touch prompts unless configured otherwise.
+const DefaultSound = "/usr/share/sounds/freedesktop/stereo/message.oga"
+
+// pending returns the sources of the touch that are waiting, restricted to
+// the given sources.
+func (t Touch) pending(sources Source) Source {
+	var pending Source
+	if t.GPG {
+		pending |= GPG
+	}
+	if t.U2F {
+		pending |= U2F
+	}
+	if t.SSH {
+		pending |= SSH
+	}
+	if t.HMAC {
+		pending |= HMAC
+	}
+	return pending & sources
+}
+
+// alerter shows a desktop notification and plays a sound while the yubikey
+// is waiting for a touch. The notification is replaced whenever the pending
+// operations change and closed once the touch completed.
+type alerter struct {
+	notifySources Source
+	soundSources  Source
+	sound         string
+
+	conn       *dbus.Conn
+	notifier   notify.Notifier
+	replacesID uint32
+}
+
+// Notify enables desktop notifications over D-Bus for the given sources.
+func (m *Module) Notify(sources Source) *Module {
+	m.alerter.notifySources = sources
+	return m
+}
+
+// Sound enables playing the given sound file with paplay for the given
+// sources. If file is empty, DefaultSound is used.
+func (m *Module) Sound(sources Source, file string) *Module {
+	if file == "" {
+		file = DefaultSound
+	}
+	m.alerter.soundSources = sources
+	m.alerter.sound = file
+	return m
+}
+
+// start connects to the session bus if notifications are enabled.
+func (a *alerter) start() {
+	if a.notifySources == 0 {
+		return
+	}
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		log.Printf("failed to connect to session bus: %v", err)
+		return
+	}
+	n, err := notify.New(conn, notify.WithOnClosed(func(s *notify.NotificationClosedSignal) {
+		atomic.CompareAndSwapUint32(&a.replacesID, s.ID, 0)
+	}))
+	if err != nil {
+		log.Printf("failed to initialize notifications: %v", err)
+		_ = conn.Close()
+		return
+	}
+	a.conn = conn
+	a.notifier = n
+}
+
+// stop closes any open notification and the session bus connection.
+func (a *alerter) stop() {
+	if a.notifier == nil {
+		return
+	}
+	if id := atomic.LoadUint32(&a.replacesID); id != 0 {
+		_, _ = a.notifier.CloseNotification(id)
+	}
+	_ = a.notifier.Close()
+	_ = a.conn.Close()
+	a.notifier = nil
+	a.conn = nil
+}
+
+// update reacts to a change of the pending operations.
+func (a *alerter) update(prev, next Touch) {
+	if newSound := next.pending(a.soundSources) &^ prev.pending(a.soundSources); newSound != 0 {
+		go func() {
+			if err := exec.Command("paplay", a.sound).Run(); err != nil {
+				log.Printf("failed to play %s: %v", a.sound, err)
+			}
+		}()
+	}
+	if a.notifier == nil || next.pending(a.notifySources) == prev.pending(a.notifySources) {
+		return
+	}
+	if next.pending(a.notifySources) == 0 {
+		if id := atomic.LoadUint32(&a.replacesID); id != 0 {
+			if _, err := a.notifier.CloseNotification(id); err != nil {
+				log.Printf("failed to close notification: %v", err)
+			}
+		}
+		return
+	}
+	id, err := a.notifier.SendNotification(notify.Notification{
+		AppName:    "i3-tools",
+		AppIcon:    "yubikey-touch-detector",
+		ReplacesID: atomic.LoadUint32(&a.replacesID),
+		Summary:    "YubiKey is waiting for a touch",
+		Body:       strings.Join(next.Reasons(), ", "),
+	})
+	if err != nil {
+		log.Printf("failed to show notification: %v", err)
+		return
+	}
+	atomic.StoreUint32(&a.replacesID, id)
+}
@@ -16,43 +16,116 @@
 package yubikey
 
 import (
+	"context"
+	"fmt"
 	"os"
 	"path"
 	"strings"
-	"sync"
+	"time"
 
 	"barista.run/bar"
 	"barista.run/base/value"
 	"barista.run/outputs"
 
-	"github.com/maximbaz/yubikey-touch-detector/detector"
 	ykNotifier "github.com/maximbaz/yubikey-touch-detector/notifier"
 )
 
+// Source is a bitmask of the operations the module watches for.
+type Source int
+
+const (
+	// GPG detects gpg-agent waiting for a touch, e.g. when signing.
+	GPG Source = 1 << iota
+	// U2F detects FIDO U2F/FIDO2 requests, e.g. from a browser.
+	U2F
+	// SSH detects ssh authentications with a key held by the yubikey. This
+	// proxies the $SSH_AUTH_SOCK socket, so it is disabled by default.
+	SSH
+	// HMAC detects HMAC-SHA1 challenge-response requests. It is disabled
+	// by default, since the module did not watch for them before.
+	HMAC
+)
+
+// DefaultSources are the sources watched unless configured otherwise.
+const DefaultSources = GPG | U2F
+
+// ParseSources converts source names (gpg, u2f, ssh, hmac) to a Source.
+func ParseSources(names []string) (Source, error) {
+	var sources Source
+	for _, name := range names {
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "gpg":
+			sources |= GPG
+		case "u2f":
+			sources |= U2F
+		case "ssh":
+			sources |= SSH
+		case "hmac":
+			sources |= HMAC
+		case "all":
+			sources |= GPG | U2F | SSH | HMAC
+		default:
+			return 0, fmt.Errorf("unknown yubikey source %q", name)
+		}
+	}
+	return sources, nil
+}
+
+// sshAttribution is how long after ssh agent traffic a pending gpg touch is
+// attributed to ssh instead.
+const sshAttribution = 2 * time.Second
+
+// Touch describes which operations are waiting for the yubikey to be touched.
+type Touch struct {
+	GPG  bool
+	U2F  bool
+	SSH  bool
+	HMAC bool
+}
+
+// Pending reports whether any operation is waiting for a touch.
+func (t Touch) Pending() bool {
+	return t.GPG || t.U2F || t.SSH || t.HMAC
+}
+
+// Reasons returns the names of all operations waiting for a touch.
+func (t Touch) Reasons() []string {
+	var reasons []string
+	if t.GPG {
+		reasons = append(reasons, "GPG")
+	}
+	if t.U2F {
+		reasons = append(reasons, "U2F")
+	}
+	if t.SSH {
+		reasons = append(reasons, "SSH")
+	}
+	if t.HMAC {
+		reasons = append(reasons, "HMAC")
+	}
+	return reasons
+}
+
 // Module represents a yubikey barista module that shows an indicator whenever
 // the plugged-in yubikey is waiting for user input.
 type Module struct {
 	gpgPubringPath string
-	outputFunc     value.Value // of func(bool, bool) bar.Output
+	sources        Source
+	alerter        alerter
+	outputFunc     value.Value // of func(Touch) bar.Output
 }
 
 // ForPath constructs a yubikey module with the given path to the gpg keyring.
 func ForPath(gpgPubringPath string) *Module {
 	m := &Module{
 		gpgPubringPath: gpgPubringPath,
+		sources:        DefaultSources,
 	}
-	m.Output(func(gpg, u2f bool) bar.Output {
-		reason := []string{}
-		if gpg {
-			reason = append(reason, "GPG")
-		}
-		if u2f {
-			reason = append(reason, "U2F")
-		}
-		if len(reason) == 0 {
+	m.Output(func(t Touch) bar.Output {
+		if !t.Pending() {
 			return nil
 		}
-		return outputs.Textf("[YK: %s]", strings.Join(reason, ","))
+		return outputs.Textf("[YK: %s]", strings.Join(t.Reasons(), ","))
 	})
 	return m
 }
@@ -67,55 +140,74 @@ func New() *Module {
 	return ForPath(os.ExpandEnv("$HOME/.gnupg/pubring.kbx"))
 }
 
+// Sources sets the operations the module watches for.
+func (m *Module) Sources(sources Source) *Module {
+	m.sources = sources
+	return m
+}
+
 // Output sets the output format for the module.
-func (m *Module) Output(outputFunc func(bool, bool) bar.Output) *Module {
+func (m *Module) Output(outputFunc func(Touch) bar.Output) *Module {
 	m.outputFunc.Set(outputFunc)
 	return m
 }
 
 // Stream starts the module.
 func (m *Module) Stream(sink bar.Sink) {
-	ykChan := make(chan ykNotifier.Message, 10)
-	notifiers := new(sync.Map)
-	notifiers.Store("barista", ykChan)
-
-	requestGPGCheck := make(chan bool)
-	go detector.CheckGPGOnRequest(requestGPGCheck, notifiers)
-	go detector.WatchU2F(notifiers)
-	go detector.WatchGPG(m.gpgPubringPath, requestGPGCheck)
-
-	exits := new(sync.Map)
-	//go detector.WatchSSH(requestGPGCheck, exits)
-	defer func(exits *sync.Map) {
-		exits.Range(func(_, value any) bool {
-			if ch, ok := value.(chan bool); ok {
-				ch <- true
-			}
-			return true
-		})
-	}(exits)
+	m.StreamContext(context.Background(), sink)
+}
+
+// StreamContext starts the module and stops it once ctx is cancelled. The
+// detectors are shared between modules, so restarting the module does not
+// start additional detectors.
+func (m *Module) StreamContext(ctx context.Context, sink bar.Sink) {
+	sub, unsubscribe := subscribe(m.gpgPubringPath, m.sources)
+	defer unsubscribe()
+
+	m.alerter.start()
+	defer m.alerter.stop()
 
-	gpg := false
-	u2f := false
-	outf := m.outputFunc.Get().(func(bool, bool) bar.Output)
+	var touch Touch
+	outf := m.outputFunc.Get().(func(Touch) bar.Output)
 	nextOutputFunc, done := m.outputFunc.Subscribe()
 	defer done()
 	for {
-		sink.Output(outf(gpg, u2f))
+		sink.Output(outf(touch))
 		select {
-		case msg := <-ykChan:
-			switch msg {
-			case ykNotifier.GPG_ON:
-				gpg = true
-			case ykNotifier.GPG_OFF:
-				gpg = false
-			case ykNotifier.U2F_ON:
-				u2f = true
-			case ykNotifier.U2F_OFF:
-				u2f = false
+		case <-sub.ready:
+			prev := touch
+			for _, msg := range sub.receive() {
+				touch = m.apply(touch, msg)
 			}
+			m.alerter.update(prev, touch)
 		case <-nextOutputFunc:
-			outf = m.outputFunc.Get().(func(bool, bool) bar.Output)
+			outf = m.outputFunc.Get().(func(Touch) bar.Output)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// apply returns the touch state after a message of the detectors.
+func (m *Module) apply(touch Touch, msg ykNotifier.Message) Touch {
+	switch msg {
+	case ykNotifier.GPG_ON:
+		if m.sources&SSH != 0 && sshActive() {
+			touch.SSH = true
+		} else {
+			touch.GPG = m.sources&GPG != 0
 		}
+	case ykNotifier.GPG_OFF:
+		touch.GPG = false
+		touch.SSH = false
+	case ykNotifier.U2F_ON:
+		touch.U2F = m.sources&U2F != 0
+	case ykNotifier.U2F_OFF:
+		touch.U2F = false
+	case ykNotifier.HMAC_ON:
+		touch.HMAC = m.sources&HMAC != 0
+	case ykNotifier.HMAC_OFF:
+		touch.HMAC = false
 	}
+	return touch
 }
@@ -4,43 +4,43 @@ go 1.21.1
 
 require (
 	barista.run v0.0.0-20230920005158-2f2fc0aa2b7a
+	github.com/BurntSushi/xgb v0.0.0-20210121224620-deaf085860bc
 	github.com/dustin/go-humanize v1.0.1
+	github.com/esiqveland/notify v0.11.1
 	github.com/fsnotify/fsnotify v1.6.0
+	github.com/godbus/dbus/v5 v5.1.0
 	github.com/lucasb-eyer/go-colorful v1.2.0
 	github.com/martinlindhe/unit v0.0.0-20230420213220-4adfd7d0a0d6
 	github.com/maximbaz/yubikey-touch-detector v0.0.0-20230921072209-a241bcf70545
 	github.com/multiplay/go-cticker v0.0.0-20190411183326-bd2f021b1c08
 	github.com/tionis/pulse.go v0.0.0-20231009153914-8d66bad7990c
 	github.com/urfave/cli/v2 v2.25.7
+	github.com/vishvananda/netlink v1.1.0
 	github.com/zalando/go-keyring v0.2.3
 	go.i3wm.org/i3/v4 v4.21.0
 	golang.org/x/crypto v0.21.0
+	golang.org/x/sys v0.18.0
+	golang.org/x/time v0.3.0
 )
 
 require (
-	github.com/BurntSushi/xgb v0.0.0-20210121224620-deaf085860bc // indirect
 	github.com/BurntSushi/xgbutil v0.0.0-20190907113008-ad855c713046 // indirect
 	github.com/alessio/shellescape v1.4.1 // indirect
 	github.com/coreos/go-systemd/v22 v22.5.0 // indirect
 	github.com/cpuguy83/go-md2man/v2 v2.0.2 // indirect
 	github.com/danieljoos/wincred v1.2.0 // indirect
-	github.com/esiqveland/notify v0.11.1 // indirect
-	github.com/godbus/dbus/v5 v5.1.0 // indirect
 	github.com/golang/protobuf v1.5.3 // indirect
 	github.com/rjeczalik/notify v0.9.2 // indirect
 	github.com/russross/blackfriday/v2 v2.1.0 // indirect
 	github.com/scylladb/go-set v1.0.2 // indirect
 	github.com/sirupsen/logrus v1.9.0 // indirect
 	github.com/spf13/afero v1.9.5 // indirect
-	github.com/vishvananda/netlink v1.1.0 // indirect
 	github.com/vishvananda/netns v0.0.1 // indirect
 	github.com/vtolstov/go-ioctl v0.0.0-20151206205506-6be9cced4810 // indirect
 	github.com/xrash/smetrics v0.0.0-20201216005158-039620a65673 // indirect
 	golang.org/x/net v0.23.0 // indirect
 	golang.org/x/oauth2 v0.12.0 // indirect
-	golang.org/x/sys v0.18.0 // indirect
 	golang.org/x/text v0.14.0 // indirect
-	golang.org/x/time v0.3.0 // indirect
 	google.golang.org/appengine v1.6.7 // indirect
 	google.golang.org/protobuf v1.33.0 // indirect
 	gopkg.in/yaml.v2 v2.4.0 // indirect
@@ -9,7 +9,11 @@ import (
 	"math/rand"
 	"os"
 	"runtime/debug"
+	"time"
 	"github.com/tionis/i3-tools/bar"
+	"github.com/tionis/i3-tools/bar/batteries"
+	"github.com/tionis/i3-tools/bar/connectivity"
+	"github.com/tionis/i3-tools/bar/yubikey"
 )
 
 func main() {
@@ -40,6 +44,24 @@ func main() {
 								Usage: "show battery status",
 								Value: true,
 							},
+							&cli.BoolFlag{
+								Name:  "battery-details",
+								Usage: "show power draw, and charge, health, cycle count and charge thresholds per battery",
+								Value: false,
+							},
+							&cli.IntSliceFlag{
+								Name:  "battery-notify",
+								Usage: "battery percentages to send a notification at when crossed",
+							},
+							&cli.StringSliceFlag{
+								Name:  "battery-action",
+								Usage: "level:action to run when discharging below level, action is notify, dim, suspend, hibernate or a shell command, e.g. 8:dim or 3:suspend",
+							},
+							&cli.DurationFlag{
+								Name:  "battery-snooze",
+								Usage: "how long clicking the battery suspends low battery actions",
+								Value: 15 * time.Minute,
+							},
 							&cli.BoolFlag{
 								Name:  "ipv6",
 								Usage: "show ipv6 status",
@@ -75,19 +97,377 @@ func main() {
 								Usage: "show ssh certificate status",
 								Value: false,
 							},
+							&cli.StringSliceFlag{
+								Name:  "ssh-cert",
+								Usage: "path or glob of ssh certificates to show (default: ~/.ssh/id_*-cert.pub)",
+							},
+							&cli.BoolFlag{
+								Name:  "ssh-cert-show-all",
+								Usage: "show every ssh certificate instead of only the one expiring soonest",
+								Value: false,
+							},
+							&cli.StringFlag{
+								Name: "ssh-cert-format",
+								Usage: "template for the ssh certificate status, e.g. " +
+									"{{.KeyId}} {{.Principals}} {{.Kind}} {{.Serial}} {{.CAFingerprint}} {{.Options}} {{.Validity}}",
+								Value: "[{{.Validity}}]",
+							},
+							&cli.StringFlag{
+								Name:  "ssh-cert-details",
+								Usage: "how to show ssh certificate details on click (notify or terminal)",
+								Value: "notify",
+							},
+							&cli.StringFlag{
+								Name: "ssh-cert-renew",
+								Usage: "command to renew the ssh certificate once less than 25% of its validity remains, " +
+									"the certificate path is passed in $SSH_CERT_PATH",
+							},
+							&cli.StringSliceFlag{
+								Name:  "x509-cert",
+								Usage: "path or glob of x509 certificates (PEM or PKCS#12 exported with openssl -legacy) to show",
+							},
+							&cli.StringSliceFlag{
+								Name:  "x509-root",
+								Usage: "path of PEM encoded CA certificates used to verify x509 certificate chains",
+							},
+							&cli.StringFlag{
+								Name:    "x509-password",
+								Usage:   "password to decrypt PKCS#12 bundles",
+								EnvVars: []string{"I3_TOOLS_X509_PASSWORD"},
+							},
+							&cli.BoolFlag{
+								Name:  "show-ssh-agent",
+								Usage: "show identities loaded into the ssh agent",
+								Value: false,
+							},
+							&cli.BoolFlag{
+								Name:  "yubikey-gpg",
+								Usage: "show yubikey touch prompts for gpg operations",
+								Value: true,
+							},
+							&cli.BoolFlag{
+								Name:  "yubikey-u2f",
+								Usage: "show yubikey touch prompts for u2f operations",
+								Value: true,
+							},
+							&cli.BoolFlag{
+								Name:  "yubikey-ssh",
+								Usage: "show yubikey touch prompts for ssh operations (proxies $SSH_AUTH_SOCK)",
+								Value: false,
+							},
+							&cli.BoolFlag{
+								Name:  "yubikey-hmac",
+								Usage: "show yubikey touch prompts for hmac challenge-response operations",
+								Value: false,
+							},
+							&cli.StringSliceFlag{
+								Name:  "yubikey-notify",
+								Usage: "sources (gpg, u2f, ssh, hmac or all) to send desktop notifications for",
+							},
+							&cli.StringSliceFlag{
+								Name:  "yubikey-sound",
+								Usage: "sources (gpg, u2f, ssh, hmac or all) to play a sound for",
+							},
+							&cli.StringFlag{
+								Name:  "yubikey-sound-file",
+								Usage: "sound file to play for yubikey touch prompts",
+								Value: yubikey.DefaultSound,
+							},
+							&cli.BoolFlag{
+								Name:  "cpu",
+								Usage: "show cpu usage with a per-core graph",
+								Value: false,
+							},
+							&cli.BoolFlag{
+								Name:  "psi",
+								Usage: "show the pressure stall information of the most contended resource",
+								Value: false,
+							},
+							&cli.Float64Flag{
+								Name:  "psi-full-threshold",
+								Usage: "percentage of full stalls over 10s above which the pressure is urgent",
+								Value: 10,
+							},
+							&cli.BoolFlag{
+								Name:  "psi-trigger",
+								Usage: "update the pressure as soon as tasks stall instead of only polling",
+								Value: true,
+							},
+							&cli.BoolFlag{
+								Name:  "top-process",
+								Usage: "show the process using the most cpu or memory when above the thresholds",
+								Value: false,
+							},
+							&cli.Float64Flag{
+								Name:  "top-process-cpu",
+								Usage: "share of a single core above which a process is shown",
+								Value: 0.9,
+							},
+							&cli.Float64Flag{
+								Name:  "top-process-mem",
+								Usage: "share of total memory above which a process is shown",
+								Value: 0.25,
+							},
+							&cli.BoolFlag{
+								Name:  "temperature",
+								Usage: "show cpu temperature",
+								Value: false,
+							},
+							&cli.StringSliceFlag{
+								Name:  "temperature-sensor",
+								Usage: "hwmon/thermal zone sensor label, name or name/label to show (default: cpu package sensors)",
+							},
+							&cli.BoolFlag{
+								Name:  "temperature-max",
+								Usage: "show the hottest of all matching sensors",
+								Value: false,
+							},
+							&cli.Float64Flag{
+								Name:  "temperature-degraded",
+								Usage: "temperature in °C above which the status is degraded",
+								Value: 75,
+							},
+							&cli.Float64Flag{
+								Name:  "temperature-bad",
+								Usage: "temperature in °C above which the status is bad",
+								Value: 90,
+							},
+							&cli.BoolFlag{
+								Name:  "connectivity",
+								Usage: "show limited connectivity and captive portals, click to open the portal login",
+								Value: false,
+							},
+							&cli.StringFlag{
+								Name:  "connectivity-probe",
+								Usage: "probe this url instead of asking networkmanager for connectivity",
+							},
+							&cli.StringFlag{
+								Name:  "connectivity-probe-body",
+								Usage: "expected response of the probe url, empty to expect 204 no content (default: networkmanager's response for its own url, otherwise empty)",
+							},
+							&cli.BoolFlag{
+								Name:  "vpn",
+								Usage: "show wireguard interfaces and networkmanager vpn connections",
+								Value: false,
+							},
+							&cli.StringSliceFlag{
+								Name:  "vpn-connection",
+								Usage: "networkmanager connections to always show, click to toggle them",
+							},
+							&cli.BoolFlag{
+								Name:  "dnd",
+								Usage: "show whether dunst notifications are paused, click to toggle do not disturb",
+								Value: false,
+							},
+							&cli.BoolFlag{
+								Name:  "dnd-fullscreen",
+								Usage: "pause notifications while a fullscreen window is focused",
+								Value: false,
+							},
+							&cli.StringSliceFlag{
+								Name:  "dnd-class",
+								Usage: "window classes to pause notifications for while focused, e.g. zoom for screen sharing",
+							},
+							&cli.BoolFlag{
+								Name:  "media",
+								Usage: "show the track of the most recently active media player while it plays",
+								Value: false,
+							},
+							&cli.StringSliceFlag{
+								Name:  "media-exclude",
+								Usage: "MPRIS players to ignore, e.g. chromium",
+							},
+							&cli.IntFlag{
+								Name:  "media-width",
+								Usage: "number of characters after which the track scrolls",
+								Value: 30,
+							},
+							&cli.BoolFlag{
+								Name:  "bluetooth",
+								Usage: "show bluetooth power and connected devices, click to toggle power",
+								Value: false,
+							},
+							&cli.StringFlag{
+								Name:  "bluetooth-favorite",
+								Usage: "address of the bluetooth device to connect or disconnect on right click",
+							},
+							&cli.BoolFlag{
+								Name:  "backlight",
+								Usage: "show screen brightness, scroll to adjust and click to toggle a dimmed level",
+								Value: false,
+							},
+							&cli.StringFlag{
+								Name:  "backlight-device",
+								Usage: "backlight in /sys/class/backlight to control (default: preferred device)",
+							},
+							&cli.BoolFlag{
+								Name:  "keyboard-layout",
+								Usage: "show the active keyboard layout if several are configured, click to switch",
+								Value: false,
+							},
+							&cli.BoolFlag{
+								Name:  "keyboard-layout-per-window",
+								Usage: "remember the keyboard layout of each window",
+								Value: false,
+							},
+							&cli.BoolFlag{
+								Name:  "lock-keys",
+								Usage: "show caps lock and num lock while they are on, click to toggle",
+								Value: false,
+							},
+							&cli.StringFlag{
+								Name:  "lock-keys-on-color",
+								Usage: "color of lock keys that are on (default: degraded color)",
+							},
+							&cli.StringFlag{
+								Name:  "lock-keys-off-color",
+								Usage: "color of lock keys that are off",
+							},
+							&cli.BoolFlag{
+								Name:  "lock-keys-show-off",
+								Usage: "also show lock keys that are off",
+								Value: false,
+							},
+							&cli.BoolFlag{
+								Name:  "lock-keys-urgent",
+								Usage: "mark the bar urgent while caps lock is on",
+								Value: false,
+							},
+							&cli.StringSliceFlag{
+								Name:  "mount",
+								Usage: "mountpoints to show the free space of (default: / unless discovering or filtering)",
+							},
+							&cli.BoolFlag{
+								Name:  "mount-discover",
+								Usage: "show the free space of all mounted disks instead of the given mountpoints",
+								Value: false,
+							},
+							&cli.StringSliceFlag{
+								Name:  "mount-include",
+								Usage: "only show mounts whose mountpoint or filesystem type matches a glob",
+							},
+							&cli.StringSliceFlag{
+								Name:  "mount-exclude",
+								Usage: "skip mounts whose mountpoint or filesystem type matches a glob",
+							},
+							&cli.StringSliceFlag{
+								Name:  "netspeed",
+								Usage: "network interfaces to show the throughput of",
+							},
+							&cli.StringSliceFlag{
+								Name:  "diskio",
+								Usage: "disks (e.g. nvme0n1, sda) to show the throughput of",
+							},
+							&cli.StringFlag{
+								Name:  "sparkline",
+								Usage: "how to render throughput history (spark, bar or none)",
+								Value: "spark",
+							},
 						},
 						Action: func(c *cli.Context) error {
+							var yubikeySources yubikey.Source
+							if c.Bool("yubikey-gpg") {
+								yubikeySources |= yubikey.GPG
+							}
+							if c.Bool("yubikey-u2f") {
+								yubikeySources |= yubikey.U2F
+							}
+							if c.Bool("yubikey-ssh") {
+								yubikeySources |= yubikey.SSH
+							}
+							if c.Bool("yubikey-hmac") {
+								yubikeySources |= yubikey.HMAC
+							}
+							yubikeyNotify, err := yubikey.ParseSources(c.StringSlice("yubikey-notify"))
+							if err != nil {
+								return err
+							}
+							yubikeySound, err := yubikey.ParseSources(c.StringSlice("yubikey-sound"))
+							if err != nil {
+								return err
+							}
+							batteryActions, err := batteries.ParseEscalation(c.StringSlice("battery-action"))
+							if err != nil {
+								return err
+							}
+							if c.IsSet("mount") && c.Bool("mount-discover") {
+								return fmt.Errorf("--mount and --mount-discover cannot be combined")
+							}
+							// Only NetworkManager's own probe url answers with
+							// its body, custom probes usually return 204.
+							probeBody := c.String("connectivity-probe-body")
+							if !c.IsSet("connectivity-probe-body") && c.String("connectivity-probe") == connectivity.DefaultProbeURL {
+								probeBody = connectivity.DefaultProbeBody
+							}
 							return bar.Status(bar.Config{
 								Ethernet:         c.Bool("ethernet"),
 								Wifi:             c.Bool("wifi"),
 								Battery:          c.Bool("battery"),
+								BatteryDetails:   c.Bool("battery-details"),
+								BatteryNotify:    c.IntSlice("battery-notify"),
+								BatteryActions:   batteryActions,
+								BatterySnooze:    c.Duration("battery-snooze"),
 								IPv6:             c.Bool("ipv6"),
 								WifiIPs:          c.Bool("wifi-ips"),
-								TerminalEmulator: "alacritty",
+								TerminalEmulator: c.String("terminal-emulator"),
 								ColorGood:        c.String("color-good"),
 								ColorDegraded:    c.String("color-degraded"),
 								ColorBad:         c.String("color-bad"),
 								ShowSSHCert:      c.Bool("show-ssh-cert"),
+								SSHCertPaths:     c.StringSlice("ssh-cert"),
+								SSHCertShowAll:   c.Bool("ssh-cert-show-all"),
+								SSHCertFormat:    c.String("ssh-cert-format"),
+								SSHCertDetails:   c.String("ssh-cert-details"),
+								SSHCertRenew:     c.String("ssh-cert-renew"),
+								X509Certs:        c.StringSlice("x509-cert"),
+								X509Roots:        c.StringSlice("x509-root"),
+								X509Password:     c.String("x509-password"),
+								ShowSSHAgent:     c.Bool("show-ssh-agent"),
+								YubikeySources:   yubikeySources,
+								YubikeyNotify:    yubikeyNotify,
+								YubikeySound:     yubikeySound,
+								YubikeySoundFile: c.String("yubikey-sound-file"),
+								CPU:              c.Bool("cpu"),
+								PSI:              c.Bool("psi"),
+								PSIFull:          c.Float64("psi-full-threshold"),
+								PSITrigger:       c.Bool("psi-trigger"),
+								TopProcess:       c.Bool("top-process"),
+								TopProcessCPU:    c.Float64("top-process-cpu"),
+								TopProcessMem:    c.Float64("top-process-mem"),
+								Temperature:      c.Bool("temperature"),
+								TempSensors:      c.StringSlice("temperature-sensor"),
+								TempMax:          c.Bool("temperature-max"),
+								TempDegraded:     c.Float64("temperature-degraded"),
+								TempBad:          c.Float64("temperature-bad"),
+								Backlight:        c.Bool("backlight"),
+								BacklightDevice:  c.String("backlight-device"),
+								KeyboardLayout:   c.Bool("keyboard-layout"),
+								LayoutPerWindow:  c.Bool("keyboard-layout-per-window"),
+								LockKeys:         c.Bool("lock-keys"),
+								LockOnColor:      c.String("lock-keys-on-color"),
+								LockOffColor:     c.String("lock-keys-off-color"),
+								LockShowOff:      c.Bool("lock-keys-show-off"),
+								LockUrgent:       c.Bool("lock-keys-urgent"),
+								Bluetooth:        c.Bool("bluetooth"),
+								BluetoothFav:     c.String("bluetooth-favorite"),
+								Media:            c.Bool("media"),
+								MediaExclude:     c.StringSlice("media-exclude"),
+								MediaWidth:       c.Int("media-width"),
+								DND:              c.Bool("dnd"),
+								DNDFullscreen:    c.Bool("dnd-fullscreen"),
+								DNDClasses:       c.StringSlice("dnd-class"),
+								Connectivity:     c.Bool("connectivity"),
+								ConnectivityURL:  c.String("connectivity-probe"),
+								ConnectivityBody: probeBody,
+								VPN:              c.Bool("vpn"),
+								VPNConnections:   c.StringSlice("vpn-connection"),
+								Mounts:           c.StringSlice("mount"),
+								MountDiscover:    c.Bool("mount-discover"),
+								MountInclude:     c.StringSlice("mount-include"),
+								MountExclude:     c.StringSlice("mount-exclude"),
+								NetspeedIfaces:   c.StringSlice("netspeed"),
+								DiskIODevices:    c.StringSlice("diskio"),
+								SparklineStyle:   c.String("sparkline"),
 							})
 						},
 					},